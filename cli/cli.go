@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/alecthomas/kong"
 	"os"
+	"os/signal"
 	"pingu/pkg"
 	"time"
 )
@@ -53,65 +55,277 @@ type UrlOptions struct {
 }
 
 type RetryOptions struct {
-	Retries        int `short:"r" name:"retries" default:"0" help:"The number of times to retry after a failed check."`
-	RetryIncrement int `short:"i" name:"retry-increment" default:"1" help:"The power to raise wait seconds after each retry. Maximum of 3. Example:  f '-i=3' seconds would be 1, 8, 27, etc...."`
+	Retries       int           `short:"r" name:"retries" group:"retry options" default:"0" help:"The number of times to retry after a failed check. Retries stop at the first check that passes."`
+	Backoff       string        `name:"backoff" group:"retry options" enum:"fixed,linear,exponential" default:"linear" help:"How the pause grows with each retry: fixed, linear or exponential."`
+	RetryDelay    time.Duration `name:"retry-delay" group:"retry options" default:"1s" help:"The pause before the first retry."`
+	MaxRetryDelay time.Duration `name:"max-retry-delay" group:"retry options" default:"0s" help:"The longest pause between retries, uncapped if 0."`
+	RetryJitter   bool          `name:"retry-jitter" group:"retry options" help:"Pause a random time up to the backoff before each retry."`
+	RetryOn       []string      `name:"retry-on" group:"retry options" help:"The failures that are retried, every failure if not given: connection, 5xx, 4xx, status, redirect or content. Example: --retry-on=connection,5xx"`
+	// RetryIncrement is kept for scripts written before the backoff options.
+	RetryIncrement int `short:"i" name:"retry-increment" group:"retry options" default:"0" help:"Deprecated, use --backoff instead. The power to raise wait seconds after each retry. Maximum of 3. Example: '-i=3' seconds would be 1, 8, 27, etc...."`
+}
+
+// RetryPolicy returns the policy of the retry options.
+func (opt *RetryOptions) RetryPolicy() pkg.RetryPolicy {
+	policy := pkg.RetryPolicy{
+		Retries:  opt.Retries,
+		Backoff:  opt.Backoff,
+		Delay:    pkg.Duration{Duration: opt.RetryDelay},
+		MaxDelay: pkg.Duration{Duration: opt.MaxRetryDelay},
+		Jitter:   opt.RetryJitter,
+		On:       opt.RetryOn,
+	}
+	if opt.RetryIncrement > 0 {
+		policy.Backoff = pkg.BackoffPower
+		policy.Exponent = opt.RetryIncrement
+		policy.Delay = pkg.Duration{Duration: time.Second}
+	}
+	return policy
 }
 
 func (opt *RetryOptions) Validate() error {
-	if opt.Retries > 0 && (opt.RetryIncrement < 1 || opt.RetryIncrement > 3) {
+	if opt.RetryIncrement != 0 && (opt.RetryIncrement < 1 || opt.RetryIncrement > 3) {
 		return errors.New("retry increments must be a value between 1 and 3")
 	}
-	return nil
+	policy := opt.RetryPolicy()
+	return policy.Validate()
+}
+
+type FlapOptions struct {
+	FlapHigh        float64 `name:"flap-high" group:"flapping options" default:"50" help:"The percent state change of the recent checks at which the url is flapping."`
+	FlapLow         float64 `name:"flap-low" group:"flapping options" default:"25" help:"The percent state change of the recent checks at which the url stops flapping."`
+	FlapWindow      int     `name:"flap-window" group:"flapping options" default:"21" help:"The number of recent checks the state change is taken from."`
+	NoFlapDetection bool    `name:"no-flap-detection" group:"flapping options" help:"Alert on every failure, even while the url is flapping."`
+}
+
+func (opt *FlapOptions) FlapPolicy() pkg.FlapPolicy {
+	return pkg.FlapPolicy{
+		High:     opt.FlapHigh,
+		Low:      opt.FlapLow,
+		Window:   opt.FlapWindow,
+		Disabled: opt.NoFlapDetection,
+	}
+}
+
+func (opt *FlapOptions) Validate() error {
+	policy := opt.FlapPolicy()
+	return policy.Validate()
+}
+
+type MetricOptions struct {
+	Statsd       string            `name:"statsd" group:"metric options" help:"Address of a StatsD server to send check metrics to over udp. Example: localhost:8125"`
+	Graphite     string            `name:"graphite" group:"metric options" help:"Address of a Graphite server to send check metrics to over tcp. Example: localhost:2003"`
+	MetricPrefix string            `name:"metric-prefix" group:"metric options" default:"pingu" help:"Prefix of the metric names."`
+	MetricTags   map[string]string `name:"metric-tag" group:"metric options" help:"Tags added to the metrics. Example: --metric-tag=env=prod"`
+}
+
+// MetricEmitters returns the StatsD and Graphite emitters of the options.
+func (opt *MetricOptions) MetricEmitters() []pkg.MetricEmitter {
+	emitters := make([]pkg.MetricEmitter, 0)
+	if opt.Statsd != "" {
+		emitters = append(emitters, pkg.NewStatsdEmitter(opt.Statsd, opt.MetricPrefix, opt.MetricTags))
+	}
+	if opt.Graphite != "" {
+		emitters = append(emitters, pkg.NewGraphiteEmitter(opt.Graphite, opt.MetricPrefix, opt.MetricTags))
+	}
+	return emitters
+}
+
+type LogOptions struct {
+	LogFormat     string `name:"log-format" group:"log options" enum:"text,json,logfmt" default:"text" help:"Format of the log output: text, json or logfmt."`
+	LogFile       string `name:"log-file" group:"log options" help:"File the log is written to instead of stdout."`
+	LogMaxSize    int64  `name:"log-max-size" group:"log options" default:"10" help:"Size in megabytes at which the log file is rotated."`
+	LogMaxBackups int    `name:"log-max-backups" group:"log options" default:"3" help:"Number of rotated log files to keep."`
+}
+
+// NewConsole creates the console and sets its log format and output. The
+// returned func closes the log file, and is deferred by the command.
+func (opt *LogOptions) NewConsole(verbosity int) (*pkg.Console, func(), error) {
+	c := pkg.NewConsole(verbosity)
+	if opt.LogFile == "" {
+		c.SetOutput(opt.LogFormat, nil)
+		return c, func() {}, nil
+	}
+
+	file, err := pkg.OpenRotatingFile(opt.LogFile, opt.LogMaxSize*1024*1024, opt.LogMaxBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.SetOutput(opt.LogFormat, file)
+	return c, func() { pkg.IgnoreOnError(file.Close()) }, nil
 }
 
 type CheckCmd struct {
 	UrlOptions
-	ExpectedStatus  int      `short:"e" name:"expect-status" group:"assertion options" default:"200" help:"The expected http status."`
-	ExpectedContent string   `short:"c" name:"expect-content" group:"assertion options" help:"A regular express that must match the returned content."`
-	IgnorePeriod    []string `name:"ignore-period" sep:";" help:"A time span during which calls to check will be ignored. Example: 'SAT 10:00PM - SUN 1:00AM'"`
+	ExpectedStatus   string   `short:"e" name:"expect-status" group:"assertion options" default:"200" help:"The expected http status, class, range or list of them. Example: 2xx, '200,204', 200-299 or '!5xx'."`
+	ExpectedContent  []string `short:"c" name:"expect-content" sep:"none" group:"assertion options" help:"A regular express that must match the returned content. May be repeated."`
+	RejectContent    []string `name:"reject-content" sep:"none" group:"assertion options" help:"A regular express that must not match the returned content. May be repeated. Example: --reject-content=Exception"`
+	ContentMatch     string   `name:"content-match" enum:"all,any" default:"all" group:"assertion options" help:"Whether all or any of the expected content must match: all or any."`
+	IgnoreCase       bool     `name:"ignore-case" group:"assertion options" help:"Match the expected and rejected content ignoring case."`
+	Literal          bool     `name:"literal" group:"assertion options" help:"Match the expected and rejected content as plain text rather than regular expressions."`
+	StatusSeverity   string   `name:"status-severity" enum:"critical,warning" default:"critical" group:"assertion options" help:"Whether an unexpected status fails the check or is only reported as a warning: critical or warning."`
+	ContentSeverity  string   `name:"content-severity" enum:"critical,warning" default:"critical" group:"assertion options" help:"Whether expected content that is missing, or rejected content that is found, fails the check or is only reported as a warning: critical or warning."`
+	FollowRedirects  string   `name:"follow-redirects" group:"redirect options" default:"10" help:"The number of redirects to follow, or 'none' to check the first response."`
+	ExpectFinalUrl   string   `name:"expect-final-url" group:"redirect options" help:"A regular express that must match the url of the final response. Example: '^https://'"`
+	ExpectRedirects  int      `name:"expect-redirects" group:"redirect options" default:"-1" help:"The number of redirects expected. Not checked if negative."`
+	ExpectRedirectTo []string `name:"expect-redirect-to" sep:"none" group:"redirect options" help:"A regular express that must match the location of a redirect. May be repeated."`
+	RedirectSeverity string   `name:"redirect-severity" enum:"critical,warning" default:"critical" group:"redirect options" help:"Whether an unexpected final url or redirect fails the check or is only reported as a warning: critical or warning."`
+	MaintCheck       bool     `name:"maint-check" help:"Still check the url during an ignore period or silence, adding the result to the MAINT record without alerting."`
+	IgnoreMode       string   `name:"ignore-mode" enum:"skip,quiet" default:"skip" help:"During an ignore period either skip the check and record MAINT, or check as usual but suppress alerts: skip or quiet."`
+	IgnoreRetries    bool     `name:"ignore-retries" help:"Do not retry failed checks during a quiet ignore period."`
+	BusinessHours    string   `name:"business-hours" help:"The days and hours during which alerts are sent. Outside them alerts are held, and a digest of the held failures is emailed when the hours open. Example: 'MON-FRI 09:00 - 17:00 America/Toronto'"`
+	Holidays         string   `name:"holidays" help:"An iCalendar (.ics) file or list of dates on which business hours are closed."`
+	IgnorePeriod     []string `name:"ignore-period" sep:";" help:"A maintenance window during which calls to check will be ignored: weekly, monthly, one-off or cron, optionally followed by a time zone. Example: 'SAT 10:00PM - SUN 1:00AM', 'first SUN of the month 02:00 - 04:00', '2026-11-03 01:00 - 04:00' or '0 2 * * SUN for 2h America/Toronto'"`
 	RetryOptions
+	FlapOptions
 	AlertThreshold int64 `short:"a" name:"alert-threshold" default:"0" help:"Alert will be raise after this many consecutive failures."`
 	Verbose        int   `short:"v" type:"counter" help:"Verbosity can have a value of 1-3. Example: --verbose=3 or -vvv."`
 	EmailOptions
+	MetricOptions
+	LogOptions
+}
+
+// CheckSpec returns the spec of the url check.
+func (cmd *CheckCmd) CheckSpec() pkg.CheckSpec {
+	rules := make([]pkg.ContentRule, 0)
+	for _, pattern := range cmd.ExpectedContent {
+		rules = append(rules, pkg.ContentRule{Pattern: pattern, IgnoreCase: cmd.IgnoreCase, Literal: cmd.Literal, Severity: cmd.ContentSeverity})
+	}
+	for _, pattern := range cmd.RejectContent {
+		rules = append(rules, pkg.ContentRule{Pattern: pattern, Negate: true, IgnoreCase: cmd.IgnoreCase, Literal: cmd.Literal, Severity: cmd.ContentSeverity})
+	}
+
+	var expectRedirects *int
+	if cmd.ExpectRedirects >= 0 {
+		expectRedirects = &cmd.ExpectRedirects
+	}
+
+	return pkg.CheckSpec{
+		Url:              cmd.Url,
+		ExpectedStatus:   pkg.StatusExpression(cmd.ExpectedStatus),
+		StatusSeverity:   cmd.StatusSeverity,
+		RedirectSeverity: cmd.RedirectSeverity,
+		ContentRules:     rules,
+		ContentMatch:     cmd.ContentMatch,
+		FollowRedirects:  pkg.RedirectLimit(cmd.FollowRedirects),
+		ExpectFinalUrl:   cmd.ExpectFinalUrl,
+		ExpectRedirects:  expectRedirects,
+		ExpectRedirectTo: cmd.ExpectRedirectTo,
+		Emitters:         cmd.MetricEmitters(),
+	}
 }
 
 func (cmd *CheckCmd) Validate() error {
-	return nil
+	err := cmd.RetryOptions.Validate()
+	if err != nil {
+		return err
+	}
+	err = cmd.FlapOptions.Validate()
+	if err != nil {
+		return err
+	}
+	err = pkg.ValidateMaintenanceWindows(cmd.IgnorePeriod)
+	if err != nil {
+		return err
+	}
+	_, err = pkg.LoadBusinessHours(cmd.BusinessHours, cmd.Holidays)
+	if err != nil {
+		return err
+	}
+	if cmd.ContentSeverity == pkg.SeverityWarning && len(cmd.ExpectedContent) == 0 && len(cmd.RejectContent) == 0 {
+		return errors.New("--content-severity=warning needs an --expect-content or --reject-content to apply to")
+	}
+	spec := cmd.CheckSpec()
+	return spec.Validate()
 }
 
 func (cmd *CheckCmd) Run(ctx *Context) error {
 
-	console = pkg.NewConsole(cmd.Verbose)
+	c, closeLog, err := cmd.NewConsole(cmd.Verbose)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	console = c.With(pkg.Fields{"monitor": pkg.NewStore(cmd.Url, cmd.StoreName).Name, "url": cmd.Url})
 
 	currentTimestamp := time.Now()
 
+	checkCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	maintenance := ""
+	quiet := false
 	for _, ignoreText := range cmd.IgnorePeriod {
 		console.Trace("Checking: %s\n", ignoreText)
 		ignore := pkg.IsIgnorePeriodActive(ignoreText, currentTimestamp)
 		if ignore == true {
-			console.Info("%s %s\n", pkg.Red("Ignore time period:"), pkg.Yellow(ignoreText))
-			console.Info("%s\n", pkg.Red("Current period is active. Exiting..."))
-			os.Exit(0)
+			console.Log(1, "check.ignored", pkg.Fields{"period": ignoreText}, "%s %s\n", pkg.Red("Ignore time period:"), pkg.Yellow(ignoreText))
+			if cmd.IgnoreMode == pkg.IgnoreQuiet {
+				quiet = true
+			} else {
+				maintenance = "ignore period: " + ignoreText
+			}
+			break
 		}
 	}
 
-	record, err := pkg.CheckCommand(cmd.Url, cmd.ExpectedStatus, cmd.ExpectedContent, cmd.StoreName, console)
+	silenced := pkg.NewStore(cmd.Url, cmd.StoreName)
+	silenced.Lock()
+	silenced.Read()
+	silenced.Unlock()
+	if silence := silenced.Data.ActiveSilence(currentTimestamp); maintenance == "" && silence != nil {
+		console.Log(1, "check.silenced", pkg.Fields{"until": silence.End, "reason": silence.Reason}, "%s %s\n", pkg.Red("Silenced:"), pkg.Yellow(silence.String()))
+		maintenance = "silenced: " + silence.ReasonText()
+	}
+
+	if maintenance != "" {
+		_, err = pkg.RecordMaintenance(checkCtx, cmd.CheckSpec(), cmd.StoreName, maintenance, cmd.MaintCheck, console)
+		return err
+	}
+
+	policy := cmd.RetryPolicy()
+	if quiet && cmd.IgnoreRetries {
+		policy.Retries = 0
+	}
+	_, store, err := pkg.RetryCheck(checkCtx, cmd.CheckSpec(), cmd.StoreName, nil, policy, console)
+	if err != nil {
+		return err
+	}
+
+	flap, _ := pkg.CheckFlapping(store, cmd.FlapPolicy(), currentTimestamp, console)
 
-	if err != nil && cmd.Retries > 0 {
-		retries := 1
-		for retries <= cmd.Retries {
-			seconds := pkg.CalculatePauseInSeconds(retries, cmd.RetryIncrement)
-			console.Debug(pkg.Green("Retry #%d in %d seconds...\n"), retries, seconds/time.Second)
-			time.Sleep(seconds)
-			record, err = pkg.CheckCommand(cmd.Url, cmd.ExpectedStatus, cmd.ExpectedContent, cmd.StoreName, console)
-			retries += 1
+	hours, err := pkg.LoadBusinessHours(cmd.BusinessHours, cmd.Holidays)
+	if err != nil {
+		return err
+	}
+
+	if len(store.Data.Held) > 0 && cmd.Email == true && hours.Open(currentTimestamp) {
+		console.Log(1, "digest.send", pkg.Fields{"held": len(store.Data.Held)}, pkg.Yellow("Sending digest of the failures held outside business hours...\n"))
+		err = pkg.SendHeldDigest(
+			pkg.NewSmtpServer(
+				cmd.EmailHost,
+				cmd.EmailPort,
+				cmd.EmailUser,
+				cmd.EmailPassword,
+			),
+			pkg.NewAlertEmail(
+				cmd.EmailFrom,
+				cmd.EmailTo,
+				cmd.EmailCc,
+				console,
+			),
+			store)
+		if err != nil {
+			return err
 		}
 	}
 
-	if err != nil && record.Count >= cmd.AlertThreshold && cmd.Email == true {
+	alerts := pkg.AlertPolicy{Threshold: cmd.AlertThreshold, Email: cmd.Email, Quiet: quiet, Hours: hours}
+	switch pkg.RouteAlert(store, flap, alerts, currentTimestamp, console) {
+	case pkg.AlertFlapping:
 		console.Dedent()
-		console.Info(pkg.Yellow("Sending Email Alert...\n"))
-		pkg.SendEmailAlert(
+		console.Log(1, "alert.send", pkg.Fields{"reason": "flapping"}, pkg.Yellow("Sending Flapping Alert...\n"))
+		err = pkg.SendFlappingAlert(
 			pkg.NewSmtpServer(
 				cmd.EmailHost,
 				cmd.EmailPort,
@@ -122,35 +336,137 @@ func (cmd *CheckCmd) Run(ctx *Context) error {
 				cmd.EmailFrom,
 				cmd.EmailTo,
 				cmd.EmailCc,
+				console,
 			),
 			cmd.Url,
-			record)
+			flap,
+			&store.Data.Current)
+		if err != nil {
+			return err
+		}
+		store.FlapAlertSent()
+	case pkg.AlertFailure:
+		console.Dedent()
+		console.Log(1, "alert.send", nil, pkg.Yellow("Sending Email Alert...\n"))
+		err = pkg.SendEmailAlert(
+			pkg.NewSmtpServer(
+				cmd.EmailHost,
+				cmd.EmailPort,
+				cmd.EmailUser,
+				cmd.EmailPassword,
+			),
+			pkg.NewAlertEmail(
+				cmd.EmailFrom,
+				cmd.EmailTo,
+				cmd.EmailCc,
+				console,
+			),
+			cmd.Url,
+			&store.Data.Current)
+		if err != nil {
+			return err
+		}
+		store.AlertSent()
 	}
 
 	return nil
 }
 
 type ReportCmd struct {
-	UrlOptions
+	Url           string   `arg:"" name:"url" optional:"" help:"Url to report on."`
+	StoreName     string   `short:"s" name:"store-name" help:"The store file name. If not supplied name will be hash of the url."`
+	All           bool     `name:"all" help:"Report on every stored url in a single digest."`
+	Format        string   `short:"f" name:"format" enum:"text,html,json,csv,markdown" default:"text" help:"Output format of the report: text, html, json, csv or markdown."`
+	UptimeWindows []string `name:"uptime-window" sep:"," default:"24h,7d,30d,month" help:"Windows over which uptime is calculated. Example: '24h,7d,30d,month'"`
+	SlaTarget     float64  `name:"sla" help:"SLA target percentage used to calculate the remaining error budget. Example: 99.9"`
+	IgnorePeriod  []string `name:"ignore-period" sep:";" help:"A maintenance window excluded from uptime calculations. Example: 'SAT 10:00PM - SUN 1:00AM' or '0 2 * * SUN for 2h'"`
+	Since         string   `name:"since" help:"Only report on history after this date, time or duration ago. Example: '2022-09-01' or '30d'"`
+	Until         string   `name:"until" help:"Only report on history before this date, time or duration ago. Example: '2022-10-01 12:00'"`
+	GroupBy       string   `name:"group-by" enum:",day,week,month" default:"" help:"Break down availability by day, week or month."`
 	EmailOptions
 }
 
+func (cmd *ReportCmd) Validate() error {
+	if cmd.All == (cmd.Url != "") {
+		return errors.New("provide either a url or --all, but not both")
+	}
+	if cmd.SlaTarget < 0 || cmd.SlaTarget >= 100 {
+		return errors.New("sla target must be a percentage between 0 and 100")
+	}
+	return nil
+}
+
+// reportOptions converts the command flags into the report options.
+func (cmd *ReportCmd) reportOptions(now time.Time) (pkg.ReportOptions, error) {
+	options := pkg.ReportOptions{
+		Windows:       cmd.UptimeWindows,
+		SlaTarget:     cmd.SlaTarget,
+		IgnorePeriods: cmd.IgnorePeriod,
+		GroupBy:       cmd.GroupBy,
+	}
+
+	var err error
+	if cmd.Since != "" {
+		options.Since, err = pkg.ParseReportTime(cmd.Since, now)
+		if err != nil {
+			return options, err
+		}
+	}
+	if cmd.Until != "" {
+		options.Until, err = pkg.ParseReportTime(cmd.Until, now)
+		if err != nil {
+			return options, err
+		}
+	}
+	if !options.Since.IsZero() && !options.Until.IsZero() && !options.Since.Before(options.Until) {
+		return options, errors.New("--since must be before --until")
+	}
+	return options, nil
+}
+
 func (cmd *ReportCmd) Run(ctx *Context) error {
-	// do check command
-	store := pkg.NewStore(cmd.Url, cmd.StoreName)
-	store.Read()
+	options, err := cmd.reportOptions(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var message pkg.ReportRenderer
+
+	if cmd.All {
+		stores, err := pkg.ListStores()
+		if err != nil {
+			return err
+		}
+		digest := &pkg.DigestMessage{Options: options}
+		for _, store := range stores {
+			digest.Stores = append(digest.Stores, store.Data)
+		}
+		message = digest
+	} else {
+		store := pkg.NewStore(cmd.Url, cmd.StoreName)
+		store.Read()
+		message = &pkg.ReportMessage{
+			Store:   store.Data,
+			Options: options,
+		}
+	}
 
-	message := pkg.ReportMessage{
-		Store: store.Data,
+	err = message.Initialize()
+	if err != nil {
+		return err
 	}
-	message.Initialize()
-	fmt.Print(message.ToText())
+
+	output, err := message.Render(cmd.Format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(output)
 
 	if cmd.Email == false {
 		return nil
 	}
 
-	pkg.SendEmailReport(
+	return pkg.SendEmailReport(
 		pkg.NewSmtpServer(
 			cmd.EmailHost,
 			cmd.EmailPort,
@@ -161,15 +477,267 @@ func (cmd *ReportCmd) Run(ctx *Context) error {
 			cmd.EmailFrom,
 			cmd.EmailTo,
 			cmd.EmailCc,
+			console,
 		),
-		&message,
+		message,
+	)
+}
+
+type StatusPageCmd struct {
+	Out          string   `short:"o" name:"out" default:"./public" help:"Directory the status page is written to."`
+	Title        string   `name:"title" default:"Service Status" help:"Title of the status page."`
+	Days         int      `name:"days" default:"90" help:"Number of days of uptime history to show."`
+	Incidents    int      `name:"incidents" default:"10" help:"Maximum number of recent incidents to list."`
+	Templates    string   `name:"templates" help:"Directory of templates that override the built-in statuspage.html."`
+	IgnorePeriod []string `name:"ignore-period" sep:";" help:"A maintenance window excluded from uptime calculations. Example: 'SAT 10:00PM - SUN 1:00AM' or '0 2 * * SUN for 2h'"`
+}
+
+func (cmd *StatusPageCmd) Validate() error {
+	if cmd.Days < 1 {
+		return errors.New("days must be 1 or more")
+	}
+	return nil
+}
+
+func (cmd *StatusPageCmd) Run(ctx *Context) error {
+	pkg.SetTemplateDirectory(cmd.Templates)
+
+	stores, err := pkg.ListStores()
+	if err != nil {
+		return err
+	}
+
+	data := make([]*pkg.StoreMaster, 0, len(stores))
+	for _, store := range stores {
+		data = append(data, store.Data)
+	}
+
+	page, err := pkg.NewStatusPage(cmd.Title, data, time.Now(), cmd.Days, cmd.Incidents, cmd.IgnorePeriod)
+	if err != nil {
+		return err
+	}
+
+	err = page.Write(cmd.Out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Status page written to %s\n", cmd.Out)
+	return nil
+}
+
+type ServeCmd struct {
+	Listen        string   `short:"l" name:"listen" default:":8080" help:"Address the http server listens on."`
+	AuthUser      string   `name:"auth-user" env:"PINGU_AUTH_USER" help:"Basic auth user name required to access the server."`
+	AuthPassword  string   `name:"auth-password" env:"PINGU_AUTH_PASSWORD" help:"Basic auth password required to access the server."`
+	UptimeWindows []string `name:"uptime-window" sep:"," default:"24h,7d,30d,month" help:"Windows over which uptime is calculated. Example: '24h,7d,30d,month'"`
+	SlaTarget     float64  `name:"sla" help:"SLA target percentage used to calculate the remaining error budget. Example: 99.9"`
+	IgnorePeriod  []string `name:"ignore-period" sep:";" help:"A maintenance window excluded from uptime calculations. Example: 'SAT 10:00PM - SUN 1:00AM' or '0 2 * * SUN for 2h'"`
+	Templates     string   `name:"templates" help:"Directory of templates that override the built-in dashboard.html."`
+	Config        string   `short:"C" name:"config" help:"Path of the monitors config file whose checks are run by the check api. Defaults to pingu.json in the user config directory, if it exists."`
+	Verbose       int      `short:"v" type:"counter" help:"Verbosity can have a value of 1-3. Example: --verbose=3 or -vvv."`
+	LogOptions
+}
+
+// LoadConfig reads the config file, or the default config file if it
+// exists. It returns nil if no path was given and there is no default.
+func (cmd *ServeCmd) LoadConfig() (*pkg.Config, error) {
+	configPath := cmd.Config
+	if configPath == "" {
+		configPath = pkg.DefaultConfigPath()
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	return pkg.LoadConfig(configPath)
+}
+
+func (cmd *ServeCmd) Validate() error {
+	if (cmd.AuthUser == "") != (cmd.AuthPassword == "") {
+		return errors.New("both --auth-user and --auth-password are required for basic auth")
+	}
+	return nil
+}
+
+func (cmd *ServeCmd) Run(ctx *Context) error {
+	c, closeLog, err := cmd.NewConsole(cmd.Verbose)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	console = c
+	pkg.SetTemplateDirectory(cmd.Templates)
+
+	config, err := cmd.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	server := pkg.NewServer(
+		pkg.ReportOptions{
+			Windows:       cmd.UptimeWindows,
+			SlaTarget:     cmd.SlaTarget,
+			IgnorePeriods: cmd.IgnorePeriod,
+		},
+		config,
+		cmd.AuthUser,
+		cmd.AuthPassword,
+		console,
 	)
+	return server.ListenAndServe(cmd.Listen)
+}
+
+type ConfigOptions struct {
+	Config      string `short:"C" name:"config" help:"Path of the monitors config file. Defaults to pingu.json in the user config directory."`
+	Concurrency int    `name:"concurrency" help:"The number of monitors checked at once. Overrides the config file, which defaults to 4."`
+	MaxPerHost  int    `name:"max-per-host" help:"The number of monitors of a single host checked at once, unlimited if negative. Overrides the config file, which defaults to 2."`
+}
+
+func (opt *ConfigOptions) Validate() error {
+	if opt.Concurrency < 0 {
+		return errors.New("--concurrency must not be negative")
+	}
+	return nil
+}
+
+// LoadConfig reads the config file, or the default config file if no path was given.
+func (opt *ConfigOptions) LoadConfig() (*pkg.Config, error) {
+	configPath := opt.Config
+	if configPath == "" {
+		configPath = pkg.DefaultConfigPath()
+	}
+	config, err := pkg.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Concurrency > 0 {
+		config.Concurrency = opt.Concurrency
+	}
+	if opt.MaxPerHost != 0 {
+		config.MaxPerHost = opt.MaxPerHost
+	}
+	return config, nil
+}
+
+type ExporterCmd struct {
+	ConfigOptions
+	Listen  string `short:"l" name:"listen" default:":9215" help:"Address the metrics server listens on."`
+	Verbose int    `short:"v" type:"counter" help:"Verbosity can have a value of 1-3. Example: --verbose=3 or -vvv."`
+	LogOptions
+}
+
+func (cmd *ExporterCmd) Run(ctx *Context) error {
+	c, closeLog, err := cmd.NewConsole(cmd.Verbose)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	console = c
+
+	config, err := cmd.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	return pkg.NewExporter(config, console).ListenAndServe(cmd.Listen)
+}
+
+type MetricsCmd struct {
+	ConfigOptions
+	Textfile string `short:"t" name:"textfile" required:"" help:"File the metrics are written to for the node_exporter textfile collector. Example: /var/lib/node_exporter/pingu.prom"`
+	Verbose  int    `short:"v" type:"counter" help:"Verbosity can have a value of 1-3. Example: --verbose=3 or -vvv."`
+	LogOptions
+}
+
+func (cmd *MetricsCmd) Run(ctx *Context) error {
+	c, closeLog, err := cmd.NewConsole(cmd.Verbose)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	console = c
+
+	config, err := cmd.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	exporter := pkg.NewExporter(config, console)
+	exporter.CheckAll()
+	return exporter.WriteTextfile(cmd.Textfile)
+}
+
+type SilenceCmd struct {
+	Target string        `arg:"" name:"url|store" help:"Url or store name to silence."`
+	For    time.Duration `name:"for" required:"" help:"How long checks of the url are ignored. Example: 2h"`
+	Reason string        `short:"r" name:"reason" help:"Why the url is silenced, which is shown in reports."`
+}
+
+func (cmd *SilenceCmd) Validate() error {
+	if cmd.For <= 0 {
+		return errors.New("--for must be a positive duration")
+	}
+	return nil
+}
+
+func (cmd *SilenceCmd) Run(ctx *Context) error {
+	store, err := pkg.FindStore(cmd.Target)
+	if err != nil {
+		return err
+	}
+
+	silence := store.Silence(time.Now(), cmd.For, cmd.Reason)
+	fmt.Printf("Silenced %s until %s.\n", store.Url, silence.End.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+type UnsilenceCmd struct {
+	Target string `arg:"" name:"url|store" help:"Url or store name to unsilence."`
+}
+
+func (cmd *UnsilenceCmd) Run(ctx *Context) error {
+	store, err := pkg.FindStore(cmd.Target)
+	if err != nil {
+		return err
+	}
+
+	if store.Unsilence(time.Now()) == 0 {
+		fmt.Printf("%s is not silenced.\n", store.Url)
+		return nil
+	}
+	fmt.Printf("Unsilenced %s.\n", store.Url)
+	return nil
+}
+
+type SilencesCmd struct{}
+
+func (cmd *SilencesCmd) Run(ctx *Context) error {
+	stores, err := pkg.ListStores()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, store := range stores {
+		silence := store.Data.ActiveSilence(now)
+		if silence == nil {
+			continue
+		}
+		fmt.Printf("%s %s until %s: %s\n", store.Name, store.Url, silence.End.Format("2006-01-02 15:04:05"), silence.ReasonText())
+	}
 	return nil
 }
 
 type CLI struct {
 	Globals
 
-	Check  CheckCmd  `cmd:""`
-	Report ReportCmd `cmd:""`
+	Check      CheckCmd      `cmd:""`
+	Report     ReportCmd     `cmd:""`
+	StatusPage StatusPageCmd `cmd:"" name:"statuspage" help:"Generate a static html status page of every stored url."`
+	Serve      ServeCmd      `cmd:"" help:"Serve a dashboard and json api of every stored url."`
+	Exporter   ExporterCmd   `cmd:"" help:"Run the configured checks on an interval and serve Prometheus metrics."`
+	Metrics    MetricsCmd    `cmd:"" help:"Run the configured checks once and write Prometheus metrics to a file."`
+	Silence    SilenceCmd    `cmd:"" help:"Stop checking a url for a while."`
+	Unsilence  UnsilenceCmd  `cmd:"" help:"Resume checking a silenced url."`
+	Silences   SilencesCmd   `cmd:"" help:"List the silenced urls."`
 }
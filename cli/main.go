@@ -3,9 +3,10 @@ package main
 import (
 	"github.com/alecthomas/kong"
 	"pingu/pkg"
+	_ "time/tzdata"
 )
 
-var console *pkg.Console
+var console pkg.Logger = pkg.NewConsole(0)
 
 func main() {
 
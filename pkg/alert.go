@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
 	"github.com/flosch/pongo2/v6"
 	"github.com/vanng822/go-premailer/premailer"
@@ -10,10 +11,97 @@ import (
 	"time"
 )
 
+/*
+AlertDue checks if an alert should be sent for the record: a failure that
+has reached the alert threshold, or one whose alert was suppressed during a
+quiet ignore period that has since ended.
+*/
+func AlertDue(record *StoreRecord, threshold int64) bool {
+	return record.Status == FAIL && (record.Count >= threshold || record.Suppressed)
+}
+
+// The alerts RouteAlert returns to be sent.
+const (
+	AlertNone     = ""
+	AlertFailure  = "failure"
+	AlertFlapping = "flapping"
+)
+
+// AlertPolicy is how the alerts of a monitor are routed, see RouteAlert.
+type AlertPolicy struct {
+	// Threshold is the number of failed checks in a row that raise an alert.
+	Threshold int64
+	// Email is true if alerts are emailed, otherwise none are sent.
+	Email bool
+	// Quiet is true during a quiet ignore period, which suppresses alerts.
+	Quiet bool
+	// Hours are the business hours alerts are sent in, always open if nil.
+	Hours *BusinessHours
+}
+
+/*
+RouteAlert decides the alert of the last check saved to the store, given
+the active flapping period, if any. An alert that is not sent now is
+suppressed during a quiet ignore period or while flapping, so it is sent
+once they end, or is held for the digest outside business hours. A
+flapping alert is sent once per flapping period, at the first check within
+business hours. It returns AlertFailure or AlertFlapping if that alert is
+to be sent, after which the caller marks it sent with AlertSent or
+FlapAlertSent, or AlertNone.
+*/
+func RouteAlert(store *Store, flap *FlapPeriod, policy AlertPolicy, now time.Time, console Logger) string {
+	record := &store.Data.Current
+	if policy.Quiet && record.Status == FAIL {
+		console.Log(1, "alert.suppressed", nil, Yellow("Alert suppressed during ignore period.\n"))
+		store.SuppressAlert()
+		return AlertNone
+	}
+
+	if flap != nil {
+		// while flapping the alerts of each failure are held, so a failure
+		// is still alerted if the url stops flapping while failing
+		if AlertDue(record, policy.Threshold) {
+			console.Log(1, "alert.suppressed", Fields{"reason": "flapping"}, Yellow("Alert suppressed while flapping.\n"))
+			store.SuppressAlert()
+		}
+		if flap.Notified || !policy.Email {
+			return AlertNone
+		}
+		if !policy.Hours.Open(now) {
+			console.Log(1, "alert.deferred", Fields{"business-hours": policy.Hours.Text}, Yellow("Flapping alert held outside business hours.\n"))
+			return AlertNone
+		}
+		return AlertFlapping
+	}
+
+	if !AlertDue(record, policy.Threshold) || !policy.Email {
+		return AlertNone
+	}
+	if !policy.Hours.Open(now) {
+		console.Log(1, "alert.deferred", Fields{"business-hours": policy.Hours.Text}, Yellow("Alert held for the digest outside business hours.\n"))
+		store.HoldAlert()
+		return AlertNone
+	}
+	return AlertFailure
+}
+
 func ComposeAlertSubject(url string) string {
 	return fmt.Sprintf("URL CHECK FAILURE: %s", url)
 }
 
+// alertFailures returns the failures of the record, or its message as a
+// single failure if it was saved without them.
+func alertFailures(record *StoreRecord) []AssertionResult {
+	if len(record.Failures) > 0 {
+		return record.Failures
+	}
+	message := strings.TrimSuffix(record.Message, "; ")
+	if message == "" {
+		return []AssertionResult{}
+	}
+	return []AssertionResult{{Severity: SeverityCritical, Message: message}}
+}
+
 func ComposeTextMessage(url string, record *StoreRecord) string {
 	var b = strings.Builder{}
 
@@ -22,10 +110,43 @@ func ComposeTextMessage(url string, record *StoreRecord) string {
 	_, _ = fmt.Fprintf(&b, "FAILURE STARTED AT: %s\r\n", record.Start)
 	_, _ = fmt.Fprintf(&b, "LAST FAILURE AT:    %s\r\n", record.Last)
 	_, _ = fmt.Fprintf(&b, "URL CHECKED %d TIMES.\r\n", record.Count)
+	if record.Step != "" {
+		_, _ = fmt.Fprintf(&b, "FAILED STEP:        %s\r\n", record.Step)
+	}
+	for _, failure := range alertFailures(record) {
+		if failure.IsWarning() {
+			_, _ = fmt.Fprintf(&b, "  - warning: %s\r\n", failure.Message)
+		} else {
+			_, _ = fmt.Fprintf(&b, "  - %s\r\n", failure.Message)
+		}
+	}
+	if len(record.Redirects) > 0 {
+		_, _ = fmt.Fprintf(&b, "REDIRECTS:\r\n")
+		for _, redirect := range record.Redirects {
+			_, _ = fmt.Fprintf(&b, "  %s\r\n", redirect)
+		}
+	}
 
 	return b.String()
 }
 
+// htmlEscaper escapes the characters pongo2 escapes in its output.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;")
+
+// markdownCellEscaper escapes a pipe, which would end a markdown table cell,
+// and breaks lines with html, as a newline would end the row.
+var markdownCellEscaper = strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// markdownCell is the mdcell template filter, which escapes a value for a
+// markdown table cell.
+func markdownCell(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+	return pongo2.AsSafeValue(markdownCellEscaper.Replace(htmlEscaper.Replace(in.String()))), nil
+}
+
+func init() {
+	PanicOnError(pongo2.RegisterFilter("mdcell", markdownCell))
+}
+
 func RenderTemplate(template string, ctx *pongo2.Context, html bool) string {
 
 	res := NewResources("templates")
@@ -50,14 +171,42 @@ func RenderTemplate(template string, ctx *pongo2.Context, html bool) string {
 
 func ComposeHtmlMessage(url string, record *StoreRecord) string {
 	data := pongo2.Context{
-		"url":    url,
-		"record": record,
+		"url":      url,
+		"record":   record,
+		"failures": alertFailures(record),
 	}
 
 	return RenderTemplate("failure-email.html", &data, true)
 
 }
 
+func ComposeFlappingSubject(url string) string {
+	return fmt.Sprintf("URL CHECK FLAPPING: %s", url)
+}
+
+func ComposeFlappingTextMessage(url string, flap *FlapPeriod, record *StoreRecord) string {
+	var b = strings.Builder{}
+
+	_, _ = fmt.Fprintf(&b, "URL CHECK FLAPPING FOR:")
+	_, _ = fmt.Fprintf(&b, "%s\r\n\r\n", url)
+	_, _ = fmt.Fprintf(&b, "FLAPPING STARTED AT: %s\r\n", flap.Start)
+	_, _ = fmt.Fprintf(&b, "STATE CHANGE:        %s\r\n", flap.PercentText())
+	_, _ = fmt.Fprintf(&b, "CURRENT STATUS:      %s\r\n", record.Status)
+	_, _ = fmt.Fprintf(&b, "NO FURTHER ALERTS ARE SENT UNTIL THE URL STOPS FLAPPING.\r\n")
+
+	return b.String()
+}
+
+func ComposeFlappingHtmlMessage(url string, flap *FlapPeriod, record *StoreRecord) string {
+	data := pongo2.Context{
+		"url":    url,
+		"flap":   flap,
+		"record": record,
+	}
+
+	return RenderTemplate("flapping-email.html", &data, true)
+}
+
 func NewSmtpServer(host string, port int, user, password string) *mail.SMTPServer {
 	server := mail.NewSMTPClient()
 	server.Host = host
@@ -94,7 +243,7 @@ func ValidEmail(email string, single bool) bool {
 	return true
 }
 
-func NewAlertEmail(from, to, cc string) *mail.Email {
+func NewAlertEmail(from, to, cc string, console Logger) *mail.Email {
 	email := mail.NewMSG()
 
 	console.Indent()
@@ -118,54 +267,114 @@ func NewAlertEmail(from, to, cc string) *mail.Email {
 	return email
 }
 
-func SendEmailAlert(server *mail.SMTPServer, email *mail.Email, url string, record *StoreRecord) {
-	email.SetSubject(ComposeAlertSubject(url))
-	email.SetBody(mail.TextPlain, ComposeTextMessage(url, record))
-	email.SetBody(mail.TextHTML, ComposeHtmlMessage(url, record))
-
-	//console.Trace(">>>>> email >>>>>>>>>>>>>>\n")
-	//console.Trace(email.GetMessage())
-	//console.Trace("<<<<< email <<<<<<<<<<<<<<\n")
-
+// sendEmail connects to the smtp server and sends the email.
+func sendEmail(server *mail.SMTPServer, email *mail.Email) error {
 	if email.Error != nil {
-		console.Print("Email construction contains errors!\n")
-		PanicOnError(email.Error)
+		return errors.New(fmt.Sprintf("email construction contains errors: %s", email.Error))
 	}
 
 	client, err := server.Connect()
 	if err != nil {
-		console.Print("SMTP server connect failed!\n")
-		PanicOnError(err)
+		return errors.New(fmt.Sprintf("smtp server connect failed: %s", err))
 	}
 
 	err = email.Send(client)
 	if err != nil {
-		console.Print("Email send failed!\n")
-		PanicOnError(err)
+		return errors.New(fmt.Sprintf("email send failed: %s", err))
 	}
+	return nil
 }
 
-func SendEmailReport(server *mail.SMTPServer, email *mail.Email, message *ReportMessage) {
-	// TODO: this is the same as the above function apart from these three lines
+func SendEmailAlert(server *mail.SMTPServer, email *mail.Email, url string, record *StoreRecord) error {
+	email.SetSubject(ComposeAlertSubject(url))
+	email.SetBody(mail.TextPlain, ComposeTextMessage(url, record))
+	email.SetBody(mail.TextHTML, ComposeHtmlMessage(url, record))
+
+	//console.Trace(">>>>> email >>>>>>>>>>>>>>\n")
+	//console.Trace(email.GetMessage())
+	//console.Trace("<<<<< email <<<<<<<<<<<<<<\n")
+
+	return sendEmail(server, email)
+}
+
+// SendFlappingAlert sends the single alert of a flapping period, which
+// stands in for the alerts of each change of status.
+func SendFlappingAlert(server *mail.SMTPServer, email *mail.Email, url string, flap *FlapPeriod, record *StoreRecord) error {
+	email.SetSubject(ComposeFlappingSubject(url))
+	email.SetBody(mail.TextPlain, ComposeFlappingTextMessage(url, flap, record))
+	email.SetBody(mail.TextHTML, ComposeFlappingHtmlMessage(url, flap, record))
+
+	return sendEmail(server, email)
+}
+
+// EmailMessage is a report that can be sent as both a text and html email.
+type EmailMessage interface {
+	Subject() string
+	ToText() string
+	ToHtml() string
+}
+
+func SendEmailReport(server *mail.SMTPServer, email *mail.Email, message EmailMessage) error {
 	email.SetSubject(message.Subject())
 	email.SetBody(mail.TextPlain, message.ToText())
 	email.SetBody(mail.TextHTML, message.ToHtml())
 
-	//console.Trace(">>>>> email >>>>>>>>>>>>>>")
-	//console.Trace(email.GetMessage())
-	//console.Trace("<<<<< email <<<<<<<<<<<<<<")
+	return sendEmail(server, email)
+}
 
-	if email.Error != nil {
-		PanicOnError(email.Error)
+/*
+SendHeldDigest sends a digest of the monitor since the first of its failures
+held outside business hours, and clears them from the store once it is
+sent.
+*/
+func SendHeldDigest(server *mail.SMTPServer, email *mail.Email, store *Store) error {
+	if len(store.Data.Held) == 0 {
+		return nil
 	}
-
-	client, err := server.Connect()
+	message := DigestMessage{
+		Stores:  []*StoreMaster{store.Data},
+		Options: ReportOptions{Since: store.Data.Held[0].Start},
+	}
+	err := message.Initialize()
 	if err != nil {
-		PanicOnError(err)
+		return err
 	}
-
-	err = email.Send(client)
+	err = SendEmailReport(server, email, &message)
 	if err != nil {
-		PanicOnError(err)
+		return err
 	}
+	store.DigestSent()
+	return nil
+}
+
+// SendConfigHeldDigest sends the digest of the held failures using the smtp
+// settings of the config.
+func SendConfigHeldDigest(config *EmailConfig, store *Store, console Logger) error {
+	return SendHeldDigest(
+		NewSmtpServer(config.Host, config.Port, config.User, config.Password),
+		NewAlertEmail(config.From, config.To, config.Cc, console),
+		store,
+	)
+}
+
+// SendConfigAlert sends an alert email using the smtp settings of the config.
+func SendConfigAlert(config *EmailConfig, url string, record *StoreRecord, console Logger) error {
+	return SendEmailAlert(
+		NewSmtpServer(config.Host, config.Port, config.User, config.Password),
+		NewAlertEmail(config.From, config.To, config.Cc, console),
+		url,
+		record,
+	)
+}
+
+// SendConfigFlappingAlert sends a flapping alert email using the smtp
+// settings of the config.
+func SendConfigFlappingAlert(config *EmailConfig, url string, flap *FlapPeriod, record *StoreRecord, console Logger) error {
+	return SendFlappingAlert(
+		NewSmtpServer(config.Host, config.Port, config.User, config.Password),
+		NewAlertEmail(config.From, config.To, config.Cc, console),
+		url,
+		flap,
+		record,
+	)
 }
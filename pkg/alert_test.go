@@ -1,12 +1,180 @@
 package pkg
 
 import (
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// smtpRecorder is a minimal smtp server that keeps the subjects of the
+// emails it is sent.
+type smtpRecorder struct {
+	mu       sync.Mutex
+	subjects []string
+}
+
+func (r *smtpRecorder) Subjects() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.subjects...)
+}
+
+func (r *smtpRecorder) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250 localhost")
+		case "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			lines, _ := text.ReadDotLines()
+			for _, l := range lines {
+				if strings.HasPrefix(l, "Subject: ") {
+					r.mu.Lock()
+					r.subjects = append(r.subjects, strings.TrimPrefix(l, "Subject: "))
+					r.mu.Unlock()
+				}
+			}
+			_ = text.PrintfLine("250 ok")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 ok")
+		}
+	}
+}
+
+// testSmtpServer starts an smtp recorder and returns the email settings
+// that send to it.
+func testSmtpServer(t *testing.T) (*EmailConfig, *smtpRecorder) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	recorder := &smtpRecorder{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go recorder.serve(conn)
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+	return &EmailConfig{Host: "127.0.0.1", Port: address.Port, From: "pingu@some.url.com", To: "ops@some.url.com"}, recorder
+}
+
 func TestParseEmailAddresses(t *testing.T) {
 	assert.Equal(t, []string{"mgemmill@mail.com"}, ParseEmailAddresses("mgemmill@mail.com"))
 	assert.Equal(t, []string{"mgemmill@mail.com", "schen@mailing.com"}, ParseEmailAddresses("mgemmill@mail.com;schen@mailing.com"))
 	assert.Equal(t, []string{}, ParseEmailAddresses(""))
 }
+
+func TestComposeMessageFailures(t *testing.T) {
+	record := &StoreRecord{Count: 2, Status: FAIL, Message: "expecting status of 200, but received 503; warning: does not contain the expected text; ", Failures: []AssertionResult{
+		{Name: "Status Code Assertion", Severity: SeverityCritical, Expected: "200", Actual: "503", Message: "expecting status of 200, but received 503"},
+		{Name: "Content Assertion", Severity: SeverityWarning, Expected: "version; 2", Actual: "no match", Message: "does not contain the expected text"},
+	}}
+
+	text := ComposeTextMessage("https://some.url.com", record)
+	assert.Contains(t, text, "  - expecting status of 200, but received 503\r\n  - warning: does not contain the expected text\r\n")
+
+	html := ComposeHtmlMessage("https://some.url.com", record)
+	assert.Contains(t, html, "expecting status of 200, but received 503 (expected 200, received 503)")
+	assert.Contains(t, html, "warning: does not contain the expected text (expected version; 2, received no match)")
+
+	// a record saved without its failures shows its message as is
+	record = &StoreRecord{Count: 2, Status: FAIL, Message: "Could not fetch url.; "}
+	assert.Contains(t, ComposeTextMessage("https://some.url.com", record), "  - Could not fetch url.\r\n")
+}
+
+func TestAlertDue(t *testing.T) {
+	assert.False(t, AlertDue(&StoreRecord{Count: 5, Status: PASS}, 3))
+	assert.False(t, AlertDue(&StoreRecord{Count: 2, Status: FAIL}, 3))
+	assert.True(t, AlertDue(&StoreRecord{Count: 3, Status: FAIL}, 3))
+	// a failure suppressed during a quiet ignore period alerts at once
+	assert.True(t, AlertDue(&StoreRecord{Count: 1, Status: FAIL, Suppressed: true}, 3))
+}
+
+func TestRouteAlert(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	console := &Console{Verbosity: -1}
+	hours, err := ParseBusinessHours("MON-FRI 09:00 - 17:00 UTC")
+	assert.Nil(t, err)
+	open := time.Date(2026, 11, 2, 10, 0, 0, 0, time.UTC)
+	closed := time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC)
+	policy := AlertPolicy{Threshold: 2, Email: true, Hours: hours}
+
+	failing := func(count int) *Store {
+		store := NewStore("https://some.url.com", "")
+		store.Read()
+		store.Data.Current = StoreRecord{}
+		for i := 0; i < count; i++ {
+			store.Save(FAIL, "Could not fetch url.; ")
+		}
+		store.Write()
+		return store
+	}
+
+	// a failure alerts once it reaches the threshold within business hours
+	assert.Equal(t, AlertNone, RouteAlert(failing(1), nil, policy, open, console))
+	assert.Equal(t, AlertFailure, RouteAlert(failing(2), nil, policy, open, console))
+	assert.Equal(t, AlertFailure, RouteAlert(failing(2), nil, AlertPolicy{Threshold: 2, Email: true}, closed, console))
+
+	// no alert is sent without email
+	store := failing(2)
+	assert.Equal(t, AlertNone, RouteAlert(store, nil, AlertPolicy{Threshold: 2, Hours: hours}, closed, console))
+	assert.False(t, store.Data.Current.Suppressed)
+	assert.Equal(t, 0, len(store.Data.Held))
+
+	// outside business hours the alert is held for the digest
+	store = failing(2)
+	assert.Equal(t, AlertNone, RouteAlert(store, nil, policy, closed, console))
+	assert.True(t, store.Data.Current.Suppressed)
+	assert.Equal(t, 1, len(store.Data.Held))
+
+	// a quiet ignore period suppresses the alert until it ends
+	store = failing(1)
+	store.Data.Held = nil
+	quiet := policy
+	quiet.Quiet = true
+	assert.Equal(t, AlertNone, RouteAlert(store, nil, quiet, open, console))
+	assert.True(t, store.Data.Current.Suppressed)
+	assert.Equal(t, AlertFailure, RouteAlert(store, nil, policy, open, console))
+
+	// while flapping a single flapping alert is sent within business hours
+	store = failing(2)
+	store.Data.Held = nil
+	flap := &FlapPeriod{Start: open, Percent: 60}
+	assert.Equal(t, AlertNone, RouteAlert(store, flap, policy, closed, console))
+	assert.True(t, store.Data.Current.Suppressed)
+	assert.Equal(t, 0, len(store.Data.Held))
+	assert.Equal(t, AlertFlapping, RouteAlert(store, flap, policy, open, console))
+	flap.Notified = true
+	assert.Equal(t, AlertNone, RouteAlert(store, flap, policy, open, console))
+}
+
+func TestSendConfigAlert(t *testing.T) {
+	config, recorder := testSmtpServer(t)
+	record := &StoreRecord{Count: 1, Status: FAIL, Message: "expecting status of 200, but received 503; "}
+	assert.Nil(t, SendConfigAlert(config, "https://some.url.com", record, &Console{Verbosity: -1}))
+	assert.Equal(t, []string{"URL CHECK FAILURE: https://some.url.com"}, recorder.Subjects())
+}
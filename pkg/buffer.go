@@ -0,0 +1,82 @@
+package pkg
+
+import "sync"
+
+type buffered struct {
+	mu    sync.Mutex
+	calls []func()
+}
+
+/*
+Buffer is a Logger that holds its events until they are flushed to another
+Logger, so the output of checks run at the same time is not interleaved and
+can be written in a fixed order. Loggers created with With buffer into the
+same list, and are flushed as the same With of the target.
+*/
+type Buffer struct {
+	parent   *Buffer
+	fields   Fields
+	target   Logger
+	buffered *buffered
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{buffered: &buffered{}}
+}
+
+// logger returns the Logger of the flush target that this buffer writes to.
+func (b *Buffer) logger() Logger {
+	if b.target == nil {
+		b.target = b.parent.logger().With(b.fields)
+	}
+	return b.target
+}
+
+func (b *Buffer) record(call func(logger Logger)) {
+	b.buffered.mu.Lock()
+	defer b.buffered.mu.Unlock()
+	b.buffered.calls = append(b.buffered.calls, func() { call(b.logger()) })
+}
+
+// Flush writes the buffered events to the target and empties the buffer.
+func (b *Buffer) Flush(target Logger) {
+	b.buffered.mu.Lock()
+	defer b.buffered.mu.Unlock()
+	b.target = target
+	for _, call := range b.buffered.calls {
+		call()
+	}
+	b.buffered.calls = nil
+}
+
+func (b *Buffer) With(fields Fields) Logger {
+	return &Buffer{parent: b, fields: fields, buffered: b.buffered}
+}
+
+func (b *Buffer) Indent() {
+	b.record(func(logger Logger) { logger.Indent() })
+}
+
+func (b *Buffer) Dedent() {
+	b.record(func(logger Logger) { logger.Dedent() })
+}
+
+func (b *Buffer) Log(verbosity int, event string, fields Fields, message string, opt ...interface{}) {
+	b.record(func(logger Logger) { logger.Log(verbosity, event, fields, message, opt...) })
+}
+
+func (b *Buffer) Print(message string, opt ...interface{}) {
+	b.record(func(logger Logger) { logger.Print(message, opt...) })
+}
+
+func (b *Buffer) Info(message string, opt ...interface{}) {
+	b.record(func(logger Logger) { logger.Info(message, opt...) })
+}
+
+func (b *Buffer) Debug(message string, opt ...interface{}) {
+	b.record(func(logger Logger) { logger.Debug(message, opt...) })
+}
+
+func (b *Buffer) Trace(message string, opt ...interface{}) {
+	b.record(func(logger Logger) { logger.Trace(message, opt...) })
+}
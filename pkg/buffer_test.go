@@ -0,0 +1,32 @@
+package pkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	buffer := NewBuffer()
+	child := buffer.With(Fields{"monitor": "api"})
+	child.Log(0, "check.pass", Fields{"status_code": 200}, "PASS GET %s\n", "https://some.url.com")
+	child.Indent()
+	child.Trace("Fetched in %dms\n", 12)
+	child.Dedent()
+	buffer.Print("done\n")
+
+	recorder := NewRecorder(3)
+	assert.Equal(t, 0, len(recorder.Events()))
+
+	buffer.Flush(recorder)
+	events := recorder.Events()
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "check.pass", events[0].Event)
+	assert.Equal(t, Fields{"monitor": "api", "status_code": 200}, events[0].Fields)
+	assert.Equal(t, 1, events[1].Indent)
+	assert.Equal(t, "Fetched in 12ms\n", events[1].Message)
+	assert.Equal(t, Fields{}, events[2].Fields)
+
+	// the buffer is emptied by the flush
+	buffer.Flush(recorder)
+	assert.Equal(t, 3, len(recorder.Events()))
+}
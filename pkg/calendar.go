@@ -0,0 +1,311 @@
+package pkg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Holiday is a span of whole days, from the Start date up to but not
+// including the End date. A Yearly holiday repeats on the same dates.
+type Holiday struct {
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Yearly bool
+}
+
+// contains returns true if the holiday includes the date of the given day.
+func (h Holiday) contains(day time.Time) bool {
+	if !h.Yearly {
+		return !day.Before(h.Start) && day.Before(h.End)
+	}
+	for _, years := range []int{day.Year() - h.Start.Year() - 1, day.Year() - h.Start.Year()} {
+		if years < 0 {
+			continue
+		}
+		if !day.Before(h.Start.AddDate(years, 0, 0)) && day.Before(h.End.AddDate(years, 0, 0)) {
+			return true
+		}
+	}
+	return false
+}
+
+// HolidayCalendar is a list of holidays, see LoadHolidays.
+type HolidayCalendar struct {
+	Holidays []Holiday
+}
+
+// Holiday returns the holiday on the date of the given time, if there is one.
+func (c *HolidayCalendar) Holiday(given time.Time) (Holiday, bool) {
+	day := time.Date(given.Year(), given.Month(), given.Day(), 0, 0, 0, 0, time.UTC)
+	for _, holiday := range c.Holidays {
+		if holiday.contains(day) {
+			return holiday, true
+		}
+	}
+	return Holiday{}, false
+}
+
+/*
+LoadHolidays reads a holiday calendar from an iCalendar file, see
+ParseICalendar, or from a simple list of dates, see ParseHolidayList.
+*/
+func LoadHolidays(filename string) (*HolidayCalendar, error) {
+	content, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	text := string(content)
+	if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(text, "\ufeff")), "BEGIN:VCALENDAR") {
+		return ParseICalendar(text)
+	}
+	return ParseHolidayList(text)
+}
+
+// parseCalendarDate parses the date of an iCalendar or holiday list value.
+func parseCalendarDate(value, layout string) (time.Time, error) {
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return date, errors.New(fmt.Sprintf("'%s' is not a valid calendar date.", value))
+	}
+	return date, nil
+}
+
+var holidayRx = regexp.MustCompile(`^(?P<start>\d{4}-\d\d-\d\d)(?: ?- ?(?P<end>\d{4}-\d\d-\d\d))?(?:\s+(?P<name>.*))?$`)
+
+/*
+ParseHolidayList accepts one holiday per line, as a date or an inclusive
+range of dates, optionally followed by its name. Blank lines and lines
+starting with # are skipped. Example:
+
+	# office holidays
+	2026-12-25 Christmas Day
+	2026-12-24 - 2026-12-31 Office closed
+*/
+func ParseHolidayList(text string) (*HolidayCalendar, error) {
+	calendar := HolidayCalendar{Holidays: make([]Holiday, 0)}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		match := submatches(holidayRx, line)
+		if len(match) == 0 {
+			return nil, errors.New(fmt.Sprintf("'%s' is not a valid holiday.", line))
+		}
+		start, err := parseCalendarDate(match["start"], "2006-01-02")
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if match["end"] != "" {
+			end, err = parseCalendarDate(match["end"], "2006-01-02")
+			if err != nil {
+				return nil, err
+			}
+			if end.Before(start) {
+				return nil, errors.New(fmt.Sprintf("'%s' ends before it starts.", line))
+			}
+		}
+
+		calendar.Holidays = append(calendar.Holidays, Holiday{
+			Name:  strings.TrimSpace(match["name"]),
+			Start: start,
+			End:   end.AddDate(0, 0, 1),
+		})
+	}
+	return &calendar, scanner.Err()
+}
+
+// unfoldICalendar joins the folded lines of an iCalendar file.
+func unfoldICalendar(text string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// icalDate returns the date of a DTSTART or DTEND value, and whether the
+// value is a time after the start of that date.
+func icalDate(value string) (time.Time, bool, error) {
+	if len(value) < 8 {
+		return time.Time{}, false, errors.New(fmt.Sprintf("'%s' is not a valid calendar date.", value))
+	}
+	date, err := parseCalendarDate(value[:8], "20060102")
+	return date, len(value) > 8 && strings.Trim(value[8:], "T0Z") != "", err
+}
+
+/*
+ParseICalendar reads the events of an iCalendar (.ics) file as holidays.
+Each event covers the dates from its DTSTART to its DTEND, or the single
+day of DTSTART if it has no end. An event with a yearly RRULE repeats every
+year, other recurrence rules are ignored. Times are taken as the date they
+fall on, as written in the file.
+*/
+func ParseICalendar(text string) (*HolidayCalendar, error) {
+	calendar := HolidayCalendar{Holidays: make([]Holiday, 0)}
+
+	var event *Holiday
+	for _, line := range unfoldICalendar(text) {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		name := strings.ToUpper(strings.SplitN(line[:colon], ";", 2)[0])
+		value := strings.TrimSpace(line[colon+1:])
+
+		switch {
+		case name == "BEGIN" && strings.ToUpper(value) == "VEVENT":
+			event = &Holiday{}
+		case event == nil:
+			continue
+		case name == "END" && strings.ToUpper(value) == "VEVENT":
+			if event.Start.IsZero() {
+				return nil, errors.New(fmt.Sprintf("the event '%s' has no start date.", event.Name))
+			}
+			if !event.End.After(event.Start) {
+				event.End = event.Start.AddDate(0, 0, 1)
+			}
+			calendar.Holidays = append(calendar.Holidays, *event)
+			event = nil
+		case name == "SUMMARY":
+			event.Name = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+		case name == "DTSTART":
+			date, _, err := icalDate(value)
+			if err != nil {
+				return nil, err
+			}
+			event.Start = date
+		case name == "DTEND":
+			date, timed, err := icalDate(value)
+			if err != nil {
+				return nil, err
+			}
+			if timed {
+				date = date.AddDate(0, 0, 1)
+			}
+			event.End = date
+		case name == "RRULE":
+			event.Yearly = strings.Contains(strings.ToUpper(value), "FREQ=YEARLY")
+		}
+	}
+
+	return &calendar, nil
+}
+
+/*
+BusinessHours are the hours of the week during which a failing url pages
+someone. Outside them alerts are held back, and the held failures are sent
+in a digest when they open. A nil BusinessHours is always open.
+*/
+type BusinessHours struct {
+	Text     string
+	Days     cronField
+	Starts   DayTime
+	Ends     DayTime
+	Holidays *HolidayCalendar
+}
+
+var businessHoursRx = regexp.MustCompile(`^(?:(?P<days>[A-Za-z0-9,\-]+) )?(?P<hours>\d{1,2}:\d\d.*)$`)
+
+/*
+ParseBusinessHours accepts the days of the week and the daily hours of the
+business, optionally followed by a time zone:
+
+	MON-FRI 09:00 - 17:00 America/Toronto
+	MON,WED,FRI 8:00 AM - 4:00 PM
+
+The days are a list or range of days, as the day of the week of a cron
+expression, and default to MON-FRI. The hours are a daily TimePeriod, see
+ParseTimePeriod, and must end after they start.
+*/
+func ParseBusinessHours(text string) (*BusinessHours, error) {
+	text = strings.TrimSpace(text)
+	match := submatches(businessHoursRx, text)
+	if len(match) == 0 {
+		return nil, errors.New(fmt.Sprintf("'%s' are not valid business hours.", text))
+	}
+
+	days := match["days"]
+	if days == "" {
+		days = "MON-FRI"
+	}
+	field, err := parseCronField(days, 0, 7, cronWeekdays)
+	if err != nil {
+		return nil, err
+	}
+	field.values[0] = field.values[0] || field.values[7]
+
+	starts, ends, err := ParseTimePeriod(match["hours"])
+	if err != nil || starts.Dow != "" || ends.Dow != "" {
+		return nil, errors.New(fmt.Sprintf("'%s' are not valid business hours.", text))
+	}
+	if ends.twentyFour()*60+ends.Minute <= starts.twentyFour()*60+starts.Minute {
+		return nil, errors.New(fmt.Sprintf("'%s' must end after they start.", text))
+	}
+
+	return &BusinessHours{Text: text, Days: field, Starts: starts, Ends: ends}, nil
+}
+
+/*
+LoadBusinessHours parses the business hours and reads their holidays file,
+if one is given. It returns nil if there are no business hours.
+*/
+func LoadBusinessHours(hours, holidays string) (*BusinessHours, error) {
+	if hours == "" {
+		if holidays != "" {
+			return nil, errors.New("holidays need business hours")
+		}
+		return nil, nil
+	}
+
+	businessHours, err := ParseBusinessHours(hours)
+	if err != nil {
+		return nil, err
+	}
+	if holidays != "" {
+		businessHours.Holidays, err = LoadHolidays(holidays)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return businessHours, nil
+}
+
+// Open returns true if the given time is within business hours and not on
+// a holiday.
+func (b *BusinessHours) Open(given time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	local := b.Starts.in(given)
+	if !b.Days.match(int(local.Weekday())) {
+		return false
+	}
+	if b.Holidays != nil {
+		if _, ok := b.Holidays.Holiday(local); ok {
+			return false
+		}
+	}
+
+	opens := time.Date(local.Year(), local.Month(), local.Day(), b.Starts.twentyFour(), b.Starts.Minute, 0, 0, local.Location())
+	closes := time.Date(local.Year(), local.Month(), local.Day(), b.Ends.twentyFour(), b.Ends.Minute, 0, 0, local.Location())
+	return !local.Before(opens) && local.Before(closes)
+}
+
+func (b *BusinessHours) String() string {
+	return b.Text
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testICalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//some.url.com//holidays//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:christmas@some.url.com\r\n" +
+	"DTSTART;VALUE=DATE:20251225\r\n" +
+	"DTEND;VALUE=DATE:20251227\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"SUMMARY:Christmas Day\\, Boxing Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:offsite@some.url.com\r\n" +
+	"DTSTART:20261102T090000Z\r\n" +
+	"DTEND:20261102T170000Z\r\n" +
+	"SUMMARY:Company\r\n" +
+	"  offsite\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261012\r\n" +
+	"SUMMARY:Thanksgiving\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICalendar(t *testing.T) {
+	calendar, err := ParseICalendar(testICalendar)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(calendar.Holidays))
+
+	// the yearly holiday repeats, up to the exclusive end date
+	holiday, ok := calendar.Holiday(time.Date(2026, 12, 26, 12, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "Christmas Day, Boxing Day", holiday.Name)
+	_, ok = calendar.Holiday(time.Date(2026, 12, 27, 12, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+	_, ok = calendar.Holiday(time.Date(2024, 12, 25, 12, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+
+	// a timed event covers the day it falls on
+	holiday, ok = calendar.Holiday(time.Date(2026, 11, 2, 20, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "Company offsite", holiday.Name)
+	_, ok = calendar.Holiday(time.Date(2026, 11, 3, 8, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+
+	// an event without an end is a single day
+	_, ok = calendar.Holiday(time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	_, ok = calendar.Holiday(time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+
+	_, err = ParseICalendar("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2026\nEND:VEVENT\nEND:VCALENDAR\n")
+	assert.EqualError(t, err, "'2026' is not a valid calendar date.")
+	_, err = ParseICalendar("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Someday\nEND:VEVENT\nEND:VCALENDAR\n")
+	assert.EqualError(t, err, "the event 'Someday' has no start date.")
+}
+
+func TestParseHolidayList(t *testing.T) {
+	calendar, err := ParseHolidayList("# office holidays\n\n2026-12-25 Christmas Day\n2026-12-28 - 2026-12-31 Office closed\n2027-01-01\n")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(calendar.Holidays))
+
+	holiday, ok := calendar.Holiday(time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "Office closed", holiday.Name)
+	_, ok = calendar.Holiday(time.Date(2026, 12, 27, 12, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+	holiday, ok = calendar.Holiday(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "", holiday.Name)
+
+	_, err = ParseHolidayList("Christmas Day")
+	assert.EqualError(t, err, "'Christmas Day' is not a valid holiday.")
+	_, err = ParseHolidayList("2026-12-31 - 2026-12-28")
+	assert.EqualError(t, err, "'2026-12-31 - 2026-12-28' ends before it starts.")
+	_, err = ParseHolidayList("2026-13-01")
+	assert.NotNil(t, err)
+}
+
+func TestLoadHolidays(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/holidays.ics", []byte(testICalendar), 0644)
+	_ = afero.WriteFile(fs, "/holidays.txt", []byte("2026-12-25 Christmas Day\n"), 0644)
+
+	calendar, err := LoadHolidays("/holidays.ics")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(calendar.Holidays))
+
+	calendar, err = LoadHolidays("/holidays.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(calendar.Holidays))
+
+	_, err = LoadHolidays("/missing.ics")
+	assert.NotNil(t, err)
+}
+
+func TestBusinessHours(t *testing.T) {
+	hours, err := ParseBusinessHours("MON-FRI 09:00 - 17:00 UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, "MON-FRI 09:00 - 17:00 UTC", hours.String())
+
+	// 2026-11-02 is a Monday and 2026-11-07 a Saturday
+	assert.False(t, hours.Open(time.Date(2026, 11, 2, 8, 59, 0, 0, time.UTC)))
+	assert.True(t, hours.Open(time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)))
+	assert.True(t, hours.Open(time.Date(2026, 11, 6, 16, 59, 0, 0, time.UTC)))
+	assert.False(t, hours.Open(time.Date(2026, 11, 6, 17, 0, 0, 0, time.UTC)))
+	assert.False(t, hours.Open(time.Date(2026, 11, 7, 12, 0, 0, 0, time.UTC)))
+
+	// the days default to MON-FRI
+	hours, err = ParseBusinessHours("8:00 AM - 4:00 PM UTC")
+	assert.Nil(t, err)
+	assert.True(t, hours.Open(time.Date(2026, 11, 3, 15, 30, 0, 0, time.UTC)))
+	assert.False(t, hours.Open(time.Date(2026, 11, 8, 15, 30, 0, 0, time.UTC)))
+
+	hours, _ = ParseBusinessHours("SAT,SUN 10:00 - 14:00 UTC")
+	assert.True(t, hours.Open(time.Date(2026, 11, 8, 10, 0, 0, 0, time.UTC)))
+	assert.False(t, hours.Open(time.Date(2026, 11, 9, 10, 0, 0, 0, time.UTC)))
+
+	for _, text := range []string{"MON-FRI", "MON-FRI 17:00 - 09:00", "MON-FRI MON 09:00 - FRI 17:00", "WEEKDAYS 09:00 - 17:00", "MON-FRI 09:00 - 17:00 Mars/Olympus"} {
+		_, err = ParseBusinessHours(text)
+		assert.NotNil(t, err, text)
+	}
+
+	// a nil BusinessHours is always open
+	var none *BusinessHours
+	assert.True(t, none.Open(time.Date(2026, 11, 7, 3, 0, 0, 0, time.UTC)))
+}
+
+func TestBusinessHoursZone(t *testing.T) {
+	hours, err := ParseBusinessHours("MON-FRI 09:00 - 17:00 America/Toronto")
+	assert.Nil(t, err)
+
+	// 09:00 in Toronto is 14:00 UTC in standard time and 13:00 UTC in daylight saving time
+	assert.False(t, hours.Open(time.Date(2026, 11, 2, 13, 30, 0, 0, time.UTC)))
+	assert.True(t, hours.Open(time.Date(2026, 11, 2, 14, 30, 0, 0, time.UTC)))
+	assert.True(t, hours.Open(time.Date(2026, 10, 30, 13, 30, 0, 0, time.UTC)))
+
+	// Friday 16:30 in Toronto is already Saturday in UTC
+	assert.True(t, hours.Open(time.Date(2026, 11, 7, 0, 30, 0, 0, time.UTC).Add(-3*time.Hour)))
+	assert.False(t, hours.Open(time.Date(2026, 11, 7, 14, 30, 0, 0, time.UTC)))
+}
+
+func TestBusinessHoursHolidays(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/holidays.ics", []byte(testICalendar), 0644)
+
+	hours, err := LoadBusinessHours("MON-FRI 09:00 - 17:00 America/Toronto", "/holidays.ics")
+	assert.Nil(t, err)
+
+	// Christmas 2026 is a Friday, checked by the date in Toronto
+	assert.False(t, hours.Open(time.Date(2026, 12, 25, 15, 0, 0, 0, time.UTC)))
+	assert.True(t, hours.Open(time.Date(2026, 12, 24, 15, 0, 0, 0, time.UTC)))
+	assert.False(t, hours.Open(time.Date(2026, 10, 12, 15, 0, 0, 0, time.UTC)))
+
+	hours, err = LoadBusinessHours("", "")
+	assert.Nil(t, err)
+	assert.Nil(t, hours)
+
+	_, err = LoadBusinessHours("", "/holidays.ics")
+	assert.EqualError(t, err, "holidays need business hours")
+	_, err = LoadBusinessHours("MON-FRI 09:00 - 17:00", "/missing.ics")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,358 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+CheckSpec describes a single url check for the library API. Example:
+
+	result, err := pkg.Check(ctx, pkg.CheckSpec{
+		Url:             "https://some.url.com/status",
+		ExpectedStatus:  "2xx",
+		ExpectedContent: "active",
+	})
+*/
+type CheckSpec struct {
+	Url             string
+	ExpectedStatus  StatusExpression
+	ExpectedContent string
+	// StatusSeverity is the severity of the expected status, and
+	// RedirectSeverity that of the redirect expectations, SeverityCritical
+	// if empty.
+	StatusSeverity   string
+	RedirectSeverity string
+	// ContentRules are checked along with the expected content. The rules
+	// that must match are combined by ContentMatch, MatchAll if empty, while
+	// every negated rule must not match.
+	ContentRules []ContentRule
+	ContentMatch string
+	// FollowRedirects is the number of redirects followed, DefaultMaxRedirects
+	// if empty, or "none".
+	FollowRedirects RedirectLimit
+	// ExpectFinalUrl is a regex the url of the last response must match.
+	ExpectFinalUrl string
+	// ExpectRedirects is the number of redirects expected, unchecked if nil.
+	ExpectRedirects *int
+	// ExpectRedirectTo are regexes that a redirect location must match.
+	ExpectRedirectTo []string
+	// Assertions are run after the expected status and content assertions.
+	Assertions []Assertion
+	// Steps make the check a scenario, whose steps are requested in turn in
+	// place of the url, see Scenario.
+	Steps []ScenarioStep
+	// Client sends the request, http.DefaultClient if nil.
+	Client *http.Client
+	// Emitters receive the metrics of the check when it is run by RunCheck
+	// or RetryCheck.
+	Emitters []MetricEmitter
+}
+
+// Validate returns an error if the spec cannot be checked.
+func (s *CheckSpec) Validate() error {
+	if len(s.Steps) > 0 {
+		return s.scenario().Validate()
+	}
+	_, err := s.FollowRedirects.Max()
+	if err != nil {
+		return err
+	}
+	_, err = s.assertions()
+	return err
+}
+
+// assertions builds the assertions of the spec, compiling every content
+// pattern so an invalid one is reported before the url is fetched.
+func (s *CheckSpec) assertions() ([]*Assertion, error) {
+	if s.Url == "" {
+		return nil, errors.New("check spec has no url")
+	}
+
+	status, err := s.expectedStatus().Parse()
+	if err != nil {
+		return nil, err
+	}
+	for _, severity := range []string{s.StatusSeverity, s.RedirectSeverity} {
+		err = ValidateSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	assertions := make([]*Assertion, 0)
+	statusAssertion := withSeverity(NewStatusSetAssertion(status), s.StatusSeverity)
+	assertions = append(assertions, &statusAssertion)
+
+	// the rules are grouped by whether they are negated and by severity,
+	// with the critical groups first
+	groups := make(map[bool]map[string][]ContentRule)
+	groups[false] = make(map[string][]ContentRule)
+	groups[true] = make(map[string][]ContentRule)
+	if s.ExpectedContent != "" {
+		groups[false][SeverityCritical] = append(groups[false][SeverityCritical], ContentRule{Pattern: s.ExpectedContent})
+	}
+	for _, rule := range s.ContentRules {
+		err = ValidateSeverity(rule.Severity)
+		if err != nil {
+			return nil, err
+		}
+		severity := rule.Severity
+		if severity == "" {
+			severity = SeverityCritical
+		}
+		groups[rule.Negate][severity] = append(groups[rule.Negate][severity], rule)
+	}
+
+	for _, severity := range []string{SeverityCritical, SeverityWarning} {
+		for _, negate := range []bool{false, true} {
+			rules := groups[negate][severity]
+			if len(rules) == 0 {
+				continue
+			}
+			match := s.ContentMatch
+			if negate {
+				match = MatchAll
+			}
+			content, err := NewContentRulesAssertion(rules, match)
+			if err != nil {
+				return nil, err
+			}
+			a := withSeverity(content, severity)
+			assertions = append(assertions, &a)
+		}
+	}
+
+	if s.ExpectFinalUrl != "" {
+		finalUrl, err := NewFinalUrlAssertion(s.ExpectFinalUrl)
+		if err != nil {
+			return nil, err
+		}
+		a := withSeverity(finalUrl, s.RedirectSeverity)
+		assertions = append(assertions, &a)
+	}
+
+	if s.ExpectRedirects != nil {
+		a := withSeverity(NewRedirectCountAssertion(*s.ExpectRedirects), s.RedirectSeverity)
+		assertions = append(assertions, &a)
+	}
+
+	for _, pattern := range s.ExpectRedirectTo {
+		target, err := NewRedirectTargetAssertion(pattern)
+		if err != nil {
+			return nil, err
+		}
+		a := withSeverity(target, s.RedirectSeverity)
+		assertions = append(assertions, &a)
+	}
+
+	for i := range s.Assertions {
+		assertions = append(assertions, &s.Assertions[i])
+	}
+
+	return assertions, nil
+}
+
+func (s *CheckSpec) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+func (s *CheckSpec) expectedStatus() StatusExpression {
+	if s.ExpectedStatus == "" {
+		return "200"
+	}
+	return s.ExpectedStatus
+}
+
+// AssertionResult is the outcome of a single assertion of a check.
+type AssertionResult struct {
+	Name string `json:"name"`
+	// Class is the failure class of the assertion: status, redirect or
+	// content, see Result.FailureClasses.
+	Class    string `json:"class,omitempty"`
+	Severity string `json:"severity"`
+	Pass     bool   `json:"pass"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Message  string `json:"message"`
+}
+
+// IsWarning returns true if the assertion is only a warning.
+func (a AssertionResult) IsWarning() bool {
+	return a.Severity == SeverityWarning
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Url        string            `json:"url"`
+	Pass       bool              `json:"pass"`
+	Checked    time.Time         `json:"checked"`
+	Response   UrlResult         `json:"response"`
+	Assertions []AssertionResult `json:"assertions"`
+	Errors     []string          `json:"errors"`
+	Warnings   []string          `json:"warnings"`
+	// Steps are the results of the steps of a scenario, up to the first
+	// that failed, which is named by FailedStep.
+	Steps      []StepResult `json:"steps,omitempty"`
+	FailedStep string       `json:"failed-step,omitempty"`
+}
+
+// Status returns PASS or FAIL.
+func (r *Result) Status() string {
+	if r.Pass {
+		return PASS
+	}
+	return FAIL
+}
+
+// Message returns the errors and warnings of the result in the form saved
+// to the store.
+func (r *Result) Message() string {
+	b := strings.Builder{}
+	for _, msg := range r.Errors {
+		_, _ = fmt.Fprintf(&b, "%s; ", msg)
+	}
+	for _, msg := range r.Warnings {
+		_, _ = fmt.Fprintf(&b, "warning: %s; ", msg)
+	}
+	return b.String()
+}
+
+/*
+Failures returns the assertions of the result that did not pass, critical
+or warning. A failure that is not an assertion, such as a url that could
+not be fetched, is returned as a critical result without a name.
+*/
+func (r *Result) Failures() []AssertionResult {
+	failures := make([]AssertionResult, 0)
+	critical := false
+	for _, assertion := range r.Assertions {
+		if !assertion.Pass {
+			failures = append(failures, assertion)
+			critical = critical || assertion.Severity != SeverityWarning
+		}
+	}
+	if !r.Pass && !critical {
+		for _, msg := range r.Errors {
+			failures = append(failures, AssertionResult{Severity: SeverityCritical, Message: msg})
+		}
+	}
+	return failures
+}
+
+/*
+Check fetches the url of the spec and runs its assertions. It has no side
+effects: nothing is printed, saved or sent, which is left to the caller
+(see Store.Record, EmitCheck and SendEmailAlert). A url that cannot be
+fetched is a failed result, while an invalid spec or a cancelled context
+is returned as an error.
+*/
+func Check(ctx context.Context, spec CheckSpec) (Result, error) {
+	if len(spec.Steps) > 0 {
+		return spec.scenario().Check(ctx)
+	}
+
+	maxRedirects, err := spec.FollowRedirects.Max()
+	if err != nil {
+		return Result{Url: spec.Url}, err
+	}
+
+	assertions, err := spec.assertions()
+	if err != nil {
+		return Result{Url: spec.Url}, err
+	}
+
+	return runCheck(ctx, spec.client(), spec.Url, maxRedirects, assertions)
+}
+
+func runCheck(ctx context.Context, client *http.Client, url string, maxRedirects int, assertions []*Assertion) (Result, error) {
+	result := Result{
+		Url:        url,
+		Checked:    time.Now(),
+		Assertions: make([]AssertionResult, 0),
+		Errors:     make([]string, 0),
+		Warnings:   make([]string, 0),
+	}
+
+	result.Response = UrlFetchContext(ctx, client, url, maxRedirects)
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	if result.Response.Fail {
+		result.Errors = append(result.Errors, "Could not fetch url.")
+		return result, nil
+	}
+
+	runAssertions(&result, assertions)
+	return result, nil
+}
+
+// runAssertions runs the assertions against the response of the result.
+func runAssertions(result *Result, assertions []*Assertion) {
+	for _, assertion := range assertions {
+		assert := *assertion
+		passed, errMsg := assert.Assert(&result.Response)
+		result.Assertions = append(result.Assertions, AssertionResult{
+			Name:     assert.Name(),
+			Class:    assertionClass(assert),
+			Severity: assert.Severity(),
+			Pass:     passed,
+			Expected: assert.Expected(),
+			Actual:   assert.Actual(&result.Response),
+			Message:  errMsg,
+		})
+
+		if passed == false {
+			if assert.Severity() == SeverityWarning {
+				result.Warnings = append(result.Warnings, errMsg)
+			} else {
+				result.Errors = append(result.Errors, errMsg)
+			}
+		}
+	}
+
+	result.Pass = len(result.Errors) == 0
+}
+
+// LogResult prints the assertions of the result to the console.
+func LogResult(result Result, console Logger) {
+	console.Indent()
+	defer console.Dedent()
+
+	if len(result.Steps) > 0 {
+		for _, step := range result.Steps {
+			console.Trace("Step %s %s %s %s\n", step.Name, step.Method, step.Url, PassFail(step.Pass))
+			logAssertions(step.Method, step.Url, step.Error, step.Assertions, console)
+		}
+		return
+	}
+
+	for _, redirect := range result.Response.Redirects {
+		console.Trace("Redirect %s\n", redirect)
+	}
+	logAssertions(http.MethodGet, result.Url, result.Response.Error, result.Assertions, console)
+}
+
+func logAssertions(method, url, fetchError string, assertions []AssertionResult, console Logger) {
+	if fetchError != "" {
+		console.Trace("Failed to fetch url: %s\n", fetchError)
+		return
+	}
+
+	for _, assertion := range assertions {
+		console.Trace("%s %s%s\n", assertion.Name, PassFail(assertion.Pass), ErrMsg(assertion.Message))
+		if assertion.Pass == false && assertion.Severity == SeverityWarning {
+			console.Print("%s %s %s %s.\n", method, url, Yellow("warning:"), assertion.Message)
+		} else if assertion.Pass == false {
+			console.Print("%s %s %s.\n", method, url, assertion.Message)
+		}
+	}
+}
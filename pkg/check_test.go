@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("status: active"))
+	}))
+	defer server.Close()
+
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL, ExpectedContent: "active"})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass)
+	assert.Equal(t, PASS, result.Status())
+	assert.Equal(t, 200, result.Response.StatusCode)
+	assert.Equal(t, []AssertionResult{
+		{Name: "Status Code Assertion", Class: FailureStatus, Severity: SeverityCritical, Pass: true, Expected: "200", Actual: "200"},
+		{Name: "Content Assertion", Class: FailureContent, Severity: SeverityCritical, Pass: true, Expected: "active", Actual: "active"},
+	}, result.Assertions)
+
+	result, err = Check(context.Background(), CheckSpec{Url: server.URL, ExpectedStatus: "204"})
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	assert.Equal(t, FAIL, result.Status())
+	assert.Equal(t, "expecting status of 204, but received 200; ", result.Message())
+	assert.Equal(t, []AssertionResult{
+		{Name: "Status Code Assertion", Class: FailureStatus, Severity: SeverityCritical, Pass: false, Expected: "204", Actual: "200", Message: "expecting status of 204, but received 200"},
+	}, result.Assertions)
+
+	// the check has no side effects
+	files, _ := afero.ReadDir(fs, dirs.UserDataDir())
+	assert.Equal(t, 0, len(files))
+}
+
+func TestCheckAllAssertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("maintenance"))
+	}))
+	defer server.Close()
+
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL, ExpectedContent: "active", ContentRules: []ContentRule{{Pattern: "version", Severity: SeverityWarning}}})
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	assert.Equal(t, 3, len(result.Assertions))
+	assert.Equal(t, []string{"expecting status of 200, but received 503", "does not contain the expected text"}, result.Errors)
+	assert.Equal(t, []string{"does not contain the expected text"}, result.Warnings)
+	assert.Equal(t, AssertionResult{
+		Name:     "Content Assertion",
+		Class:    FailureContent,
+		Severity: SeverityWarning,
+		Pass:     false,
+		Expected: "version",
+		Actual:   "no match",
+		Message:  "does not contain the expected text",
+	}, result.Assertions[2])
+	assert.Equal(t, "expecting status of 200, but received 503; does not contain the expected text; warning: does not contain the expected text; ", result.Message())
+}
+
+func TestCheckWarningPasses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("active"))
+	}))
+	defer server.Close()
+
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL, ContentRules: []ContentRule{{Pattern: "version", Severity: SeverityWarning}}})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass)
+	assert.Equal(t, PASS, result.Status())
+	assert.Equal(t, "warning: does not contain the expected text; ", result.Message())
+}
+
+func TestResultFailures(t *testing.T) {
+	status := AssertionResult{Name: "Status Code Assertion", Severity: SeverityCritical, Message: "expecting status of 200, but received 503"}
+	content := AssertionResult{Name: "Content Assertion", Severity: SeverityWarning, Message: "does not contain the expected text"}
+	passed := AssertionResult{Name: "Final Url Assertion", Severity: SeverityCritical, Pass: true}
+
+	result := Result{Assertions: []AssertionResult{status, passed, content}, Errors: []string{status.Message}}
+	assert.Equal(t, []AssertionResult{status, content}, result.Failures())
+
+	// a failure that is not an assertion is a critical result
+	result = Result{Response: UrlResult{Fail: true}, Errors: []string{"Could not fetch url."}}
+	assert.Equal(t, []AssertionResult{{Severity: SeverityCritical, Message: "Could not fetch url."}}, result.Failures())
+
+	assert.Equal(t, []AssertionResult{}, (&Result{Pass: true}).Failures())
+}
+
+func TestCheckSeverity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("status: active, Deprecated api"))
+	}))
+	defer server.Close()
+
+	// a status mismatch with a warning severity does not fail the check
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL, StatusSeverity: SeverityWarning})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass)
+	assert.Equal(t, AssertionResult{
+		Name:     "Status Code Assertion",
+		Class:    FailureStatus,
+		Severity: SeverityWarning,
+		Pass:     false,
+		Expected: "200",
+		Actual:   "202",
+		Message:  "expecting status of 200, but received 202",
+	}, result.Assertions[0])
+	assert.Equal(t, []string{"expecting status of 200, but received 202"}, result.Warnings)
+	assert.Equal(t, 0, len(result.Errors))
+
+	// a warning content rule is reported apart from the critical rules
+	result, err = Check(context.Background(), CheckSpec{
+		Url:            server.URL,
+		ExpectedStatus: "2xx",
+		ContentRules: []ContentRule{
+			{Pattern: "active"},
+			{Pattern: "Deprecated", Negate: true, Severity: SeverityWarning},
+		},
+		ExpectFinalUrl:   "^https://",
+		RedirectSeverity: SeverityWarning,
+	})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass)
+	assert.Equal(t, 4, len(result.Assertions))
+	assert.Equal(t, []string{"contains the rejected text 'Deprecated'", "final url " + server.URL + " does not match '^https://'"}, result.Warnings)
+
+	_, err = Check(context.Background(), CheckSpec{Url: server.URL, StatusSeverity: "major"})
+	assert.EqualError(t, err, "'major' is an unknown severity, expecting critical or warning.")
+	_, err = Check(context.Background(), CheckSpec{Url: server.URL, ContentRules: []ContentRule{{Pattern: "active", Severity: "minor"}}})
+	assert.EqualError(t, err, "'minor' is an unknown severity, expecting critical or warning.")
+}
+
+func TestCheckFetchFailure(t *testing.T) {
+	result, err := Check(context.Background(), CheckSpec{Url: "http://127.0.0.1:1"})
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	assert.True(t, result.Response.Fail)
+	assert.NotEmpty(t, result.Response.Error)
+	assert.Equal(t, []string{"Could not fetch url."}, result.Errors)
+}
+
+func TestCheckInvalidSpec(t *testing.T) {
+	_, err := Check(context.Background(), CheckSpec{})
+	assert.EqualError(t, err, "check spec has no url")
+
+	_, err = Check(context.Background(), CheckSpec{Url: "http://127.0.0.1:1", ExpectedContent: "(active"})
+	assert.NotNil(t, err)
+}
+
+func TestCheckContextDeadline(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Check(ctx, CheckSpec{Url: server.URL})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStoreRecord(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	store := NewStore("https://some.url.com", "some-url")
+	store.Read()
+	store.Record(Result{Pass: false, Errors: []string{"expecting status of 200, but received 503"}})
+	store.Record(Result{Pass: false, Errors: []string{"expecting status of 200, but received 503"}})
+
+	saved := NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, FAIL, saved.Data.Current.Status)
+	assert.Equal(t, int64(2), saved.Data.Current.Count)
+	assert.Equal(t, "expecting status of 200, but received 503; ", saved.Data.Current.Message)
+}
+
+func redirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/home", http.StatusFound)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("welcome"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCheckRedirects(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	two := 2
+	result, err := Check(context.Background(), CheckSpec{
+		Url:              server.URL + "/old",
+		ExpectFinalUrl:   "/home$",
+		ExpectRedirects:  &two,
+		ExpectRedirectTo: []string{"/login$"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass, result.Errors)
+	assert.Equal(t, server.URL+"/home", result.Response.FinalUrl)
+	assert.Equal(t, []Redirect{
+		{Url: server.URL + "/old", StatusCode: 301, Location: server.URL + "/login"},
+		{Url: server.URL + "/login", StatusCode: 302, Location: server.URL + "/home"},
+	}, result.Response.Redirects)
+
+	result, err = Check(context.Background(), CheckSpec{
+		Url:              server.URL + "/old",
+		ExpectFinalUrl:   "/old$",
+		ExpectRedirectTo: []string{"^https://"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"final url " + server.URL + "/home does not match '/old$'",
+		"no redirect to '^https://'",
+	}, result.Errors)
+}
+
+func TestCheckFollowRedirects(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL + "/old", FollowRedirects: "none", ExpectedStatus: "301"})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass, result.Errors)
+	assert.Equal(t, server.URL+"/old", result.Response.FinalUrl)
+	assert.Equal(t, []Redirect{{Url: server.URL + "/old", StatusCode: 301, Location: server.URL + "/login"}}, result.Response.Redirects)
+
+	result, err = Check(context.Background(), CheckSpec{Url: server.URL + "/old", FollowRedirects: "1"})
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	assert.Equal(t, 302, result.Response.StatusCode)
+	assert.Equal(t, 2, len(result.Response.Redirects))
+
+	_, err = Check(context.Background(), CheckSpec{Url: server.URL + "/old", FollowRedirects: "some"})
+	assert.NotNil(t, err)
+}
+
+func TestStoreRecordRedirects(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	redirects := []Redirect{{Url: "http://some.url.com", StatusCode: 301, Location: "https://some.url.com/login"}}
+	store := NewStore("http://some.url.com", "some-url")
+	store.Read()
+	store.Record(Result{Pass: false, Errors: []string{"no redirect to '^https://some.url.com/$'"}, Response: UrlResult{Redirects: redirects}})
+
+	saved := NewStore("http://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, redirects, saved.Data.Current.Redirects)
+	assert.Equal(t, []AssertionResult{{Severity: SeverityCritical, Message: "no redirect to '^https://some.url.com/$'"}}, saved.Data.Current.Failures)
+
+	text := ComposeTextMessage(saved.Url, &saved.Data.Current)
+	assert.Contains(t, text, "REDIRECTS:\r\n  301 http://some.url.com -> https://some.url.com/login\r\n")
+	html := ComposeHtmlMessage(saved.Url, &saved.Data.Current)
+	assert.Contains(t, html, "https://some.url.com/login")
+}
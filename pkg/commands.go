@@ -1,40 +1,97 @@
 package pkg
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"strings"
 )
 
-func CheckCommand(url string, expectedStatus int, expectedContent string, storeName string, console *Console) (*StoreRecord, error) {
+/*
+RunCheck checks the spec and prints the result, then saves it to its store
+and emits its metrics with the given tags to the emitters of the spec. It
+returns the result along with the updated store, or an error if the spec
+is invalid or the context is cancelled, in which case nothing is saved.
+*/
+func RunCheck(ctx context.Context, spec CheckSpec, storeName string, tags map[string]string, console Logger) (Result, *Store, error) {
 
-	assertions := BuildAssertions(expectedStatus, expectedContent)
-
-	urlCheck := NewUrlCheck(url, *assertions)
-
-	urlCheck.Test()
+	console.Trace("Fetching url: %s\n", spec.Url)
+	result, err := Check(ctx, spec)
+	if err != nil {
+		return result, nil, err
+	}
+	LogResult(result, console)
 
-	store := NewStore(url, storeName)
+	store := NewStore(spec.Url, storeName)
+	store.Lock()
+	defer store.Unlock()
 	store.Read()
 
-	if urlCheck.Pass == true {
-		console.Print("%s GET %s\n", Green(PASS), url)
-		store.Save(PASS, "")
-		store.Write()
-		return nil, nil
+	fields := Fields{
+		"monitor":     store.Name,
+		"url":         spec.Url,
+		"status_code": result.Response.StatusCode,
+		"duration_ms": result.Response.Timing.Total.Milliseconds(),
 	}
 
-	b := strings.Builder{}
+	if result.Pass == true {
+		console.Log(0, "check.pass", fields, "%s GET %s\n", Green(PASS), spec.Url)
+	}
 
 	console.Indent()
-	for _, msg := range urlCheck.Errors {
-		console.Print("%s: %s\n", Red(FAIL), msg)
-		_, _ = fmt.Fprintf(&b, "%s; ", msg)
+	for _, msg := range result.Errors {
+		console.Log(0, "check.fail", fields, "%s: %s\n", Red(FAIL), msg)
+	}
+	for _, msg := range result.Warnings {
+		console.Log(0, "check.warn", fields, "%s: %s\n", Yellow("WARN"), msg)
 	}
 	console.Dedent()
 
-	store.Save(FAIL, b.String())
-	store.Write()
+	store.Record(result)
+	EmitCheck(spec.Emitters, result, store, tags, console)
+
+	return result, store, nil
+}
+
+/*
+RecordMaintenance saves a MAINT status, with the reason as its message, to
+the store of a url ignored during a maintenance window. If check is true
+the url is still checked for information, without alerts or metrics, and
+the result is added to the message.
+*/
+func RecordMaintenance(ctx context.Context, spec CheckSpec, storeName, reason string, check bool, console Logger) (*Store, error) {
+	message := reason
+	if check {
+		console.Trace("Fetching url: %s\n", spec.Url)
+		result, err := Check(ctx, spec)
+		if err != nil {
+			return nil, err
+		}
+		LogResult(result, console)
+		message = fmt.Sprintf("%s; check %s; %s", reason, result.Status(), result.Message())
+	}
+
+	store := NewStore(spec.Url, storeName)
+	store.Lock()
+	defer store.Unlock()
+	store.Read()
+
+	console.Log(0, "check.maint", Fields{"monitor": store.Name, "url": spec.Url, "reason": reason}, "%s GET %s %s\n", Yellow(MAINT), spec.Url, reason)
+	store.Maintain(message)
+
+	return store, nil
+}
+
+func CheckCommand(url string, expectedStatus StatusExpression, expectedContent string, storeName string, console Logger) (*StoreRecord, error) {
+
+	spec := CheckSpec{Url: url, ExpectedStatus: expectedStatus, ExpectedContent: expectedContent}
+	result, store, err := RunCheck(context.Background(), spec, storeName, nil, console)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Pass == true {
+		return nil, nil
+	}
 
 	return &store.Data.Current, errors.New("url check failed")
 
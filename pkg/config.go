@@ -0,0 +1,247 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration that is read from json as a duration string.
+// Example: "90s", "5m" or "1h"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(text)
+	return err
+}
+
+// unmarshalNumberOrString reads a json string, or an integer as its text.
+func unmarshalNumberOrString(data []byte) (string, error) {
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err == nil {
+		return text, nil
+	}
+	var number int
+	err = json.Unmarshal(data, &number)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(number), nil
+}
+
+// EmailConfig holds the smtp settings used to send alerts.
+type EmailConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Cc       string `json:"cc"`
+}
+
+// MetricsConfig holds the address of a StatsD or Graphite server.
+type MetricsConfig struct {
+	Address string            `json:"address"`
+	Prefix  string            `json:"prefix"`
+	Tags    map[string]string `json:"tags"`
+}
+
+// MonitorConfig defines a single url check.
+type MonitorConfig struct {
+	Url              string            `json:"url"`
+	StoreName        string            `json:"store-name"`
+	ExpectedStatus   StatusExpression  `json:"expect-status"`
+	ExpectedContent  string            `json:"expect-content"`
+	StatusSeverity   string            `json:"status-severity"`
+	RedirectSeverity string            `json:"redirect-severity"`
+	RejectContent    []string          `json:"reject-content"`
+	ContentRules     []ContentRule     `json:"content-rules"`
+	ContentMatch     string            `json:"content-match"`
+	FollowRedirects  RedirectLimit     `json:"follow-redirects"`
+	ExpectFinalUrl   string            `json:"expect-final-url"`
+	ExpectRedirects  *int              `json:"expect-redirects"`
+	ExpectRedirectTo []string          `json:"expect-redirect-to"`
+	IgnorePeriods    []string          `json:"ignore-periods"`
+	MaintCheck       bool              `json:"maint-check"`
+	IgnoreMode       string            `json:"ignore-mode"`
+	BusinessHours    string            `json:"business-hours"`
+	Holidays         string            `json:"holidays"`
+	Hours            *BusinessHours    `json:"-"`
+	Interval         Duration          `json:"interval"`
+	AlertThreshold   int64             `json:"alert-threshold"`
+	Retry            RetryPolicy       `json:"retry"`
+	Flapping         FlapPolicy        `json:"flapping"`
+	Steps            []ScenarioStep    `json:"steps"`
+	Tags             map[string]string `json:"tags"`
+}
+
+// CheckSpec returns the spec used to check the monitor.
+func (m *MonitorConfig) CheckSpec() CheckSpec {
+	rules := append([]ContentRule{}, m.ContentRules...)
+	for _, pattern := range m.RejectContent {
+		rules = append(rules, ContentRule{Pattern: pattern, Negate: true})
+	}
+
+	return CheckSpec{
+		Url:              m.Url,
+		ExpectedStatus:   m.ExpectedStatus,
+		ExpectedContent:  m.ExpectedContent,
+		StatusSeverity:   m.StatusSeverity,
+		RedirectSeverity: m.RedirectSeverity,
+		ContentRules:     rules,
+		ContentMatch:     m.ContentMatch,
+		FollowRedirects:  m.FollowRedirects,
+		ExpectFinalUrl:   m.ExpectFinalUrl,
+		ExpectRedirects:  m.ExpectRedirects,
+		ExpectRedirectTo: m.ExpectRedirectTo,
+		Steps:            m.Steps,
+	}
+}
+
+// StoreId returns the id of the monitor's store.
+func (m *MonitorConfig) StoreId() string {
+	return getStoreId(m.Url, m.StoreName)
+}
+
+/*
+Config is the pingu configuration file, which defines the monitors checked
+by the long-running commands. Example:
+
+	{
+	  "interval": "1m",
+	  "concurrency": 8,
+	  "max-per-host": 2,
+	  "email": {"host": "smtp.some.url.com", "port": 25, "from": "pingu@some.url.com", "to": "ops@some.url.com"},
+	  "statsd": {"address": "localhost:8125", "prefix": "pingu", "tags": {"env": "prod"}},
+	  "monitors": [
+	    {"url": "https://some.url.com/status", "expect-content": "active", "alert-threshold": 3, "tags": {"team": "web"}},
+	    {"url": "https://some.url.com/shop", "retry": {"retries": 3, "backoff": "exponential", "max-delay": "30s", "jitter": true, "on": ["connection", "5xx"]}},
+	    {"url": "https://some.url.com/api", "expect-status": 204, "interval": "30s", "ignore-periods": ["0 2 * * SUN for 2h"], "flapping": {"high": 40, "low": 20}},
+	    {"url": "https://intranet.some.url.com", "business-hours": "MON-FRI 09:00 - 17:00 America/Toronto", "holidays": "holidays.ics"},
+	    {"store-name": "orders", "steps": [
+	      {"name": "login", "url": "https://some.url.com/login", "form": {"user": "pingu", "password": "secret"}, "extract": [{"name": "token", "json-path": "$.token"}]},
+	      {"name": "orders", "url": "https://some.url.com/api/orders", "headers": {"Authorization": "Bearer ${token}"}, "expect-content": "orders"}
+	    ]}
+	  ]
+	}
+
+Concurrency is the number of monitors checked at once and max-per-host the
+number of monitors of a single host, which is unlimited if negative. A
+monitor with steps checks a scenario (see Scenario), and its url is that
+of the first step unless it is given.
+*/
+type Config struct {
+	Interval    Duration        `json:"interval"`
+	Concurrency int             `json:"concurrency"`
+	MaxPerHost  int             `json:"max-per-host"`
+	Email       *EmailConfig    `json:"email"`
+	Statsd      *MetricsConfig  `json:"statsd"`
+	Graphite    *MetricsConfig  `json:"graphite"`
+	Monitors    []MonitorConfig `json:"monitors"`
+}
+
+// MetricEmitters returns the StatsD and Graphite emitters of the config.
+func (c *Config) MetricEmitters() []MetricEmitter {
+	emitters := make([]MetricEmitter, 0)
+	if c.Statsd != nil {
+		emitters = append(emitters, NewStatsdEmitter(c.Statsd.Address, c.Statsd.Prefix, c.Statsd.Tags))
+	}
+	if c.Graphite != nil {
+		emitters = append(emitters, NewGraphiteEmitter(c.Graphite.Address, c.Graphite.Prefix, c.Graphite.Tags))
+	}
+	return emitters
+}
+
+// DefaultConfigPath returns the location of the configuration file in the
+// user config directory.
+func DefaultConfigPath() string {
+	return path.Join(dirs.UserConfigDir(), "pingu.json")
+}
+
+// LoadConfig reads the configuration file and fills in the defaults.
+func LoadConfig(configPath string) (*Config, error) {
+	content, err := afero.ReadFile(fs, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{}
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid config %s: %s", configPath, err))
+	}
+
+	if config.Interval.Duration == 0 {
+		config.Interval.Duration = time.Minute
+	}
+	if config.Concurrency == 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.Concurrency < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid config %s: concurrency must not be negative", configPath))
+	}
+	if config.MaxPerHost == 0 {
+		config.MaxPerHost = DefaultMaxPerHost
+	}
+
+	for i := range config.Monitors {
+		monitor := &config.Monitors[i]
+		if monitor.Url == "" && len(monitor.Steps) > 0 {
+			monitor.Url = monitor.Steps[0].Url
+		}
+		if monitor.Url == "" {
+			return nil, errors.New(fmt.Sprintf("invalid config %s: monitor %d has no url", configPath, i+1))
+		}
+		if monitor.ExpectedStatus == "" {
+			monitor.ExpectedStatus = "200"
+		}
+		if monitor.Interval.Duration == 0 {
+			monitor.Interval = config.Interval
+		}
+		if monitor.IgnoreMode == "" {
+			monitor.IgnoreMode = IgnoreSkip
+		}
+		if monitor.IgnoreMode != IgnoreSkip && monitor.IgnoreMode != IgnoreQuiet {
+			return nil, errors.New(fmt.Sprintf("invalid config %s: monitor %d ignore-mode must be skip or quiet", configPath, i+1))
+		}
+		if monitor.Holidays != "" && !path.IsAbs(monitor.Holidays) {
+			monitor.Holidays = path.Join(path.Dir(configPath), monitor.Holidays)
+		}
+		spec := monitor.CheckSpec()
+		err = spec.Validate()
+		if err == nil {
+			err = ValidateMaintenanceWindows(monitor.IgnorePeriods)
+		}
+		if err == nil {
+			err = monitor.Retry.Validate()
+		}
+		if err == nil {
+			err = monitor.Flapping.Validate()
+		}
+		if err == nil {
+			monitor.Hours, err = LoadBusinessHours(monitor.BusinessHours, monitor.Holidays)
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid config %s: monitor %d %s", configPath, i+1, err))
+		}
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/pingu.json", []byte(`{
+		"interval": "5m",
+		"monitors": [
+			{"url": "https://markgemmill.com", "expect-content": "active"},
+			{"url": "https://markgemmill.com/api", "store-name": "api", "expect-status": 204, "interval": "30s", "ignore-periods": ["first SUN of the month 02:00 - 04:00"], "retry": {"retries": 3, "backoff": "exponential", "max-delay": "30s", "on": ["connection", "5xx"]}}
+		]
+	}`), 0644)
+
+	config, err := LoadConfig("/pingu.json")
+	assert.Nil(t, err)
+	assert.Nil(t, config.Email)
+	assert.Equal(t, 2, len(config.Monitors))
+
+	assert.Equal(t, StatusExpression("200"), config.Monitors[0].ExpectedStatus)
+	assert.Equal(t, 5*time.Minute, config.Monitors[0].Interval.Duration)
+	assert.Equal(t, getStoreId("https://markgemmill.com", ""), config.Monitors[0].StoreId())
+
+	assert.Equal(t, StatusExpression("204"), config.Monitors[1].ExpectedStatus)
+	assert.Equal(t, 30*time.Second, config.Monitors[1].Interval.Duration)
+	assert.Equal(t, "api", config.Monitors[1].StoreId())
+	assert.Equal(t, []string{"first SUN of the month 02:00 - 04:00"}, config.Monitors[1].IgnorePeriods)
+	assert.Equal(t, IgnoreSkip, config.Monitors[1].IgnoreMode)
+	assert.Equal(t, 0, config.Monitors[0].Retry.Retries)
+	assert.Equal(t, RetryPolicy{Retries: 3, Backoff: BackoffExponential, MaxDelay: Duration{30 * time.Second}, On: []string{FailureConnection, Failure5xx}}, config.Monitors[1].Retry)
+}
+
+func TestLoadConfigBusinessHours(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/etc/pingu/holidays.txt", []byte("2026-12-25 Christmas Day\n"), 0644)
+	_ = afero.WriteFile(fs, "/etc/pingu/pingu.json", []byte(`{
+		"monitors": [
+			{"url": "https://markgemmill.com"},
+			{"url": "https://markgemmill.com/intranet", "business-hours": "MON-FRI 09:00 - 17:00 UTC", "holidays": "holidays.txt"}
+		]
+	}`), 0644)
+
+	config, err := LoadConfig("/etc/pingu/pingu.json")
+	assert.Nil(t, err)
+	assert.Nil(t, config.Monitors[0].Hours)
+	assert.Equal(t, "/etc/pingu/holidays.txt", config.Monitors[1].Holidays)
+	assert.True(t, config.Monitors[1].Hours.Open(time.Date(2026, 12, 24, 10, 0, 0, 0, time.UTC)))
+	assert.False(t, config.Monitors[1].Hours.Open(time.Date(2026, 12, 25, 10, 0, 0, 0, time.UTC)))
+
+	_ = afero.WriteFile(fs, "/etc/pingu/bad-hours.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "business-hours": "MON-FRI 17:00 - 09:00"}]}`), 0644)
+	_, err = LoadConfig("/etc/pingu/bad-hours.json")
+	assert.EqualError(t, err, "invalid config /etc/pingu/bad-hours.json: monitor 1 'MON-FRI 17:00 - 09:00' must end after they start.")
+}
+
+func TestMonitorConfigContentRules(t *testing.T) {
+	monitor := MonitorConfig{
+		Url:           "https://markgemmill.com",
+		RejectContent: []string{"Exception"},
+		ContentRules:  []ContentRule{{Pattern: "ACTIVE", IgnoreCase: true}},
+		ContentMatch:  MatchAny,
+	}
+
+	spec := monitor.CheckSpec()
+	assert.Equal(t, []ContentRule{{Pattern: "ACTIVE", IgnoreCase: true}, {Pattern: "Exception", Negate: true}}, spec.ContentRules)
+	assert.Equal(t, MatchAny, spec.ContentMatch)
+	assert.Nil(t, spec.Validate())
+
+	monitor.StatusSeverity = SeverityWarning
+	monitor.RedirectSeverity = SeverityWarning
+	spec = monitor.CheckSpec()
+	assert.Equal(t, SeverityWarning, spec.StatusSeverity)
+	assert.Equal(t, SeverityWarning, spec.RedirectSeverity)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/missing-url.json", []byte(`{"monitors": [{"expect-status": 200}]}`), 0644)
+	_, err := LoadConfig("/missing-url.json")
+	assert.NotNil(t, err)
+
+	_ = afero.WriteFile(fs, "/bad-interval.json", []byte(`{"interval": "often"}`), 0644)
+	_, err = LoadConfig("/bad-interval.json")
+	assert.NotNil(t, err)
+
+	_ = afero.WriteFile(fs, "/bad-content.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "reject-content": ["(Exception"]}]}`), 0644)
+	_, err = LoadConfig("/bad-content.json")
+	assert.NotNil(t, err)
+
+	_ = afero.WriteFile(fs, "/bad-ignore-period.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "ignore-periods": ["0 2 * * SUN"]}]}`), 0644)
+	_, err = LoadConfig("/bad-ignore-period.json")
+	assert.NotNil(t, err)
+
+	_ = afero.WriteFile(fs, "/bad-ignore-mode.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "ignore-mode": "loud"}]}`), 0644)
+	_, err = LoadConfig("/bad-ignore-mode.json")
+	assert.EqualError(t, err, "invalid config /bad-ignore-mode.json: monitor 1 ignore-mode must be skip or quiet")
+
+	_ = afero.WriteFile(fs, "/bad-retry.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "retry": {"retries": 3, "on": ["timeout"]}}]}`), 0644)
+	_, err = LoadConfig("/bad-retry.json")
+	assert.NotNil(t, err)
+
+	_ = afero.WriteFile(fs, "/bad-flapping.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "flapping": {"high": 20, "low": 30}}]}`), 0644)
+	_, err = LoadConfig("/bad-flapping.json")
+	assert.EqualError(t, err, "invalid config /bad-flapping.json: monitor 1 the low flapping threshold 30% must be below the high threshold 20%.")
+
+	_ = afero.WriteFile(fs, "/bad-severity.json", []byte(`{"monitors": [{"url": "https://markgemmill.com", "status-severity": "info"}]}`), 0644)
+	_, err = LoadConfig("/bad-severity.json")
+	assert.EqualError(t, err, "invalid config /bad-severity.json: monitor 1 'info' is an unknown severity, expecting critical or warning.")
+
+	_, err = LoadConfig("/does-not-exist.json")
+	assert.NotNil(t, err)
+}
@@ -1,10 +1,17 @@
 package pkg
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/fatih/color"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 /*
@@ -24,21 +31,94 @@ func init() {
 	Yellow = color.New(color.FgYellow).SprintFunc()
 }
 
+const (
+	LogText   = "text"
+	LogJson   = "json"
+	LogLogfmt = "logfmt"
+)
+
+var levelNames = []string{"print", "info", "debug", "trace"}
+
+// levelName returns the name of the verbosity, clamped to the known levels.
+func levelName(verbosity int) string {
+	if verbosity < 0 {
+		return levelNames[0]
+	}
+	if verbosity >= len(levelNames) {
+		return levelNames[len(levelNames)-1]
+	}
+	return levelNames[verbosity]
+}
+
+// Fields are the structured values attached to a log event.
+type Fields map[string]interface{}
+
+var ansiRx = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// StripAnsi removes the terminal colour codes from the text.
+func StripAnsi(text string) string {
+	return ansiRx.ReplaceAllString(text, "")
+}
+
+/*
+Logger is the output of pingu. Each check should log through its own
+logger, created with With, so that concurrent checks keep their own
+indentation and fields.
+*/
+type Logger interface {
+	Print(message string, opt ...interface{})
+	Info(message string, opt ...interface{})
+	Debug(message string, opt ...interface{})
+	Trace(message string, opt ...interface{})
+	Log(verbosity int, event string, fields Fields, message string, opt ...interface{})
+	Indent()
+	Dedent()
+	With(fields Fields) Logger
+}
+
+// Console is the Logger that writes to stdout or a log file.
 type Console struct {
 	Verbosity    int
 	IndentAmount int
+	Format       string
+	Writer       io.Writer
+	Color        bool
+	Fields       Fields
+	mu           *sync.Mutex
 }
 
-var console Console
-var once sync.Once
-
 func NewConsole(verbosity int) *Console {
-	once.Do(func() {
-		console = Console{
-			Verbosity: verbosity,
-		}
-	})
-	return &console
+	return &Console{
+		Verbosity: verbosity,
+		Color:     !color.NoColor,
+		mu:        &sync.Mutex{},
+	}
+}
+
+/*
+SetOutput sets the log format and the writer events are written to. If
+the writer is nil, events are written to stdout. Colour codes are only
+kept for text written to a terminal.
+*/
+func (c *Console) SetOutput(format string, w io.Writer) {
+	c.Format = format
+	c.Writer = w
+	c.Color = w == nil && !color.NoColor
+}
+
+// With returns a console with its own indentation that adds the fields to
+// every event. It shares the writer of the parent console.
+func (c *Console) With(fields Fields) Logger {
+	child := *c
+	child.IndentAmount = 0
+	child.Fields = Fields{}
+	for key, value := range c.Fields {
+		child.Fields[key] = value
+	}
+	for key, value := range fields {
+		child.Fields[key] = value
+	}
+	return &child
 }
 
 func (c *Console) Indent() {
@@ -62,26 +142,103 @@ func (c *Console) indentMessage(message string) string {
 	return indention + message
 }
 
-func (c *Console) Print(message string, opt ...interface{}) {
-	if c.Verbosity >= 0 {
-		fmt.Printf(c.indentMessage(message), opt...)
+func (c *Console) write(text string) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
 	}
+	_, _ = io.WriteString(c.writer(), text)
 }
 
-func (c *Console) Info(message string, opt ...interface{}) {
-	if c.Verbosity >= 1 {
-		fmt.Printf(c.indentMessage(message), opt...)
+func (c *Console) writer() io.Writer {
+	if c.Writer == nil {
+		return os.Stdout
 	}
+	return c.Writer
 }
 
-func (c *Console) Debug(message string, opt ...interface{}) {
-	if c.Verbosity >= 2 {
-		fmt.Printf(c.indentMessage(message), opt...)
+/*
+Log writes an event of the given type if the verbosity allows. In text
+format only the message is written, while the json and logfmt formats
+write the timestamp, level, event type, console fields and event fields
+on a single line.
+*/
+func (c *Console) Log(verbosity int, event string, fields Fields, message string, opt ...interface{}) {
+	if c.Verbosity < verbosity {
+		return
+	}
+
+	text := fmt.Sprintf(message, opt...)
+
+	if c.Format == "" || c.Format == LogText {
+		if c.Writer != nil && !c.Color {
+			text = StripAnsi(text)
+		}
+		c.write(c.indentMessage(text))
+		return
 	}
+
+	record := Fields{}
+	for key, value := range c.Fields {
+		record[key] = value
+	}
+	for key, value := range fields {
+		record[key] = value
+	}
+	record["time"] = time.Now().Format(time.RFC3339Nano)
+	record["level"] = levelName(verbosity)
+	record["event"] = event
+	record["msg"] = strings.TrimSpace(StripAnsi(text))
+
+	var line string
+	if c.Format == LogJson {
+		content, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		line = string(content)
+	} else {
+		line = logfmt(record)
+	}
+	c.write(line + "\n")
 }
 
-func (c *Console) Trace(message string, opt ...interface{}) {
-	if c.Verbosity >= 3 {
-		fmt.Printf(c.indentMessage(message), opt...)
+// logfmt formats the record as key=value pairs, with the standard keys first.
+func logfmt(record Fields) string {
+	keys := make([]string, 0, len(record))
+	for key := range record {
+		switch key {
+		case "time", "level", "event", "msg":
+		default:
+			keys = append(keys, key)
+		}
 	}
+	sort.Strings(keys)
+	keys = append([]string{"time", "level", "event", "msg"}, keys...)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := fmt.Sprint(record[key])
+		if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+func (c *Console) Print(message string, opt ...interface{}) {
+	c.Log(0, "message", nil, message, opt...)
+}
+
+func (c *Console) Info(message string, opt ...interface{}) {
+	c.Log(1, "message", nil, message, opt...)
+}
+
+func (c *Console) Debug(message string, opt ...interface{}) {
+	c.Log(2, "message", nil, message, opt...)
+}
+
+func (c *Console) Trace(message string, opt ...interface{}) {
+	c.Log(3, "message", nil, message, opt...)
 }
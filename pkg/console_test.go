@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConsoleText(t *testing.T) {
+	out := bytes.Buffer{}
+	c := Console{Verbosity: 1}
+	c.SetOutput(LogText, &out)
+
+	c.Print("%s GET %s\n", "\x1b[32mPASS\x1b[0m", "https://some.url.com")
+	c.Indent()
+	c.Info("indented\n")
+	c.Debug("hidden\n")
+
+	assert.Equal(t, "PASS GET https://some.url.com\n  indented\n", out.String())
+}
+
+func TestConsoleJson(t *testing.T) {
+	out := bytes.Buffer{}
+	parent := NewConsole(0)
+	parent.SetOutput(LogJson, &out)
+	c := parent.With(Fields{"monitor": "some-url-com"})
+
+	c.Log(0, "check.pass", Fields{"url": "https://some.url.com", "status_code": 200}, "%s GET %s\n", Green(PASS), "https://some.url.com")
+	c.Info("hidden\n")
+
+	event := map[string]interface{}{}
+	err := json.Unmarshal(out.Bytes(), &event)
+	assert.Nil(t, err)
+	assert.Equal(t, "print", event["level"])
+	assert.Equal(t, "check.pass", event["event"])
+	assert.Equal(t, "PASS GET https://some.url.com", event["msg"])
+	assert.Equal(t, "some-url-com", event["monitor"])
+	assert.Equal(t, "https://some.url.com", event["url"])
+	assert.Equal(t, float64(200), event["status_code"])
+	assert.NotEmpty(t, event["time"])
+}
+
+func TestConsoleLevels(t *testing.T) {
+	out := bytes.Buffer{}
+	c := NewConsole(5)
+	c.SetOutput(LogLogfmt, &out)
+
+	// levels beyond the named ones do not panic
+	c.Log(-1, "check.pass", nil, "negative\n")
+	c.Log(5, "check.pass", nil, "very verbose\n")
+	assert.Contains(t, out.String(), "level=print event=check.pass msg=negative\n")
+	assert.Contains(t, out.String(), "level=trace event=check.pass msg=\"very verbose\"\n")
+}
+
+func TestConsoleLogfmt(t *testing.T) {
+	out := bytes.Buffer{}
+	c := Console{Verbosity: 3}
+	c.SetOutput(LogLogfmt, &out)
+
+	c.Log(2, "check.retry", Fields{"retry": 1, "url": "https://some.url.com"}, "Retry #%d in %d seconds...\n", 1, 1)
+
+	line := out.String()
+	assert.True(t, strings.HasPrefix(line, "time="))
+	assert.Contains(t, line, ` level=debug event=check.retry msg="Retry #1 in 1 seconds..." retry=1 url=https://some.url.com`)
+	assert.True(t, strings.HasSuffix(line, "\n"))
+}
+
+func TestConsoleWith(t *testing.T) {
+	out := bytes.Buffer{}
+	parent := NewConsole(0)
+	parent.SetOutput(LogText, &out)
+
+	first := parent.With(Fields{"monitor": "first"})
+	second := parent.With(Fields{"monitor": "second"})
+	first.Indent()
+	first.Print("first\n")
+	second.Print("second\n")
+
+	assert.Equal(t, "  first\nsecond\n", out.String())
+}
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(3)
+	check := NewUrlCheck(server.URL, *BuildAssertions(200, ""))
+	check.Test(recorder.With(Fields{"monitor": "test"}))
+
+	assert.False(t, check.Pass)
+	events := recorder.Events()
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "test", events[0].Fields["monitor"])
+	assert.Equal(t, "Fetching url: "+server.URL+"\n", events[0].Message)
+	assert.Equal(t, 1, events[2].Indent)
+	assert.Equal(t, "  GET "+server.URL+" expecting status of 200, but received 503.\n", strings.SplitAfter(recorder.Output(), "\n")[2])
+}
+
+func TestRotatingFile(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	f, err := OpenRotatingFile("/logs/pingu.log", 10, 2)
+	assert.Nil(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = f.Write([]byte(line))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, f.Close())
+
+	content, _ := afero.ReadFile(fs, "/logs/pingu.log")
+	assert.Equal(t, "fourth\n", string(content))
+	content, _ = afero.ReadFile(fs, "/logs/pingu.log.1")
+	assert.Equal(t, "third\n", string(content))
+	content, _ = afero.ReadFile(fs, "/logs/pingu.log.2")
+	assert.Equal(t, "second\n", string(content))
+	exists, _ := afero.Exists(fs, "/logs/pingu.log.3")
+	assert.False(t, exists)
+}
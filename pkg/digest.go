@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/flosch/pongo2/v6"
+	"strings"
+	"time"
+)
+
+// DigestSummary is the one line overview of a monitor in the digest.
+type DigestSummary struct {
+	Url         string       `json:"url"`
+	StoreId     string       `json:"store-id"`
+	Status      string       `json:"status"`
+	Uptime      *UptimeStats `json:"uptime,omitempty"`
+	Incidents   int          `json:"incidents"`
+	WorstOutage ReportRecord `json:"worst-outage"`
+	Silenced    *Silence     `json:"silenced,omitempty"`
+	Flapping    *FlapPeriod  `json:"flapping,omitempty"`
+}
+
+func (d DigestSummary) UptimeText() string {
+	if d.Uptime == nil {
+		return ""
+	}
+	return d.Uptime.UptimeText()
+}
+
+func (d DigestSummary) WorstOutageText() string {
+	if d.WorstOutage.Status == "" {
+		return "none"
+	}
+	return d.WorstOutage.DurationText()
+}
+
+// WorstOutage returns the longest FAIL record of the report.
+func (r *Report) WorstOutage() ReportRecord {
+	worst := ReportRecord{}
+	for _, record := range r.Records() {
+		if record.Status == FAIL && (worst.Status == "" || record.Duration > worst.Duration) {
+			worst = record
+		}
+	}
+	return worst
+}
+
+// Summarize returns the digest overview of the report. Uptime and incidents
+// are taken from the first uptime window, and the status of a flapping
+// monitor is FLAPPING.
+func (r *Report) Summarize() DigestSummary {
+	summary := DigestSummary{
+		Url:         r.Url,
+		StoreId:     r.StoreId,
+		Status:      r.Current.Status,
+		WorstOutage: r.WorstOutage(),
+	}
+	if r.Flapping != nil {
+		summary.Status = FLAPPING
+		summary.Flapping = r.Flapping
+	}
+	for i := range r.Silences {
+		if r.Silences[i].Active(r.Generated) {
+			summary.Silenced = &r.Silences[i]
+		}
+	}
+	if len(r.Uptime) > 0 {
+		summary.Uptime = &r.Uptime[0]
+		summary.Incidents = r.Uptime[0].Incidents
+	}
+	return summary
+}
+
+// Digest is the model shared by the digest renderers.
+type Digest struct {
+	Generated time.Time       `json:"generated"`
+	Summary   []DigestSummary `json:"summary"`
+	Reports   []*Report       `json:"reports"`
+}
+
+// DigestMessage creates a single combined report of many data stores.
+type DigestMessage struct {
+	Stores  []*StoreMaster
+	Options ReportOptions
+	Digest  *Digest
+	context pongo2.Context
+}
+
+func (d *DigestMessage) Initialize() error {
+	d.Digest = &Digest{
+		Generated: time.Now(),
+		Summary:   make([]DigestSummary, 0, len(d.Stores)),
+		Reports:   make([]*Report, 0, len(d.Stores)),
+	}
+
+	for _, store := range d.Stores {
+		report := NewReport(store, d.Digest.Generated)
+		err := report.Apply(d.Options)
+		if err != nil {
+			return err
+		}
+		d.Digest.Reports = append(d.Digest.Reports, report)
+		d.Digest.Summary = append(d.Digest.Summary, report.Summarize())
+	}
+
+	window := ""
+	if len(d.Options.Windows) > 0 {
+		window = d.Options.Windows[0]
+	}
+
+	d.context = pongo2.Context{
+		"digest": d.Digest,
+		"window": window,
+	}
+	return nil
+}
+
+func (d *DigestMessage) Subject() string {
+	failing := 0
+	flapping := 0
+	for _, summary := range d.Digest.Summary {
+		if summary.Status == FAIL {
+			failing += 1
+		}
+		if summary.Status == FLAPPING {
+			flapping += 1
+		}
+	}
+	if flapping > 0 {
+		return fmt.Sprintf("URL CHECK DIGEST: %d monitors, %d failing, %d flapping", len(d.Digest.Summary), failing, flapping)
+	}
+	return fmt.Sprintf("URL CHECK DIGEST: %d monitors, %d failing", len(d.Digest.Summary), failing)
+}
+
+func (d *DigestMessage) ToHtml() string {
+	return RenderTemplate("digest-email.html", &d.context, true)
+}
+
+func (d *DigestMessage) ToText() string {
+	return RenderTemplate("digest-email.txt", &d.context, false)
+}
+
+func (d *DigestMessage) ToMarkdown() string {
+	return RenderTemplate("digest.md", &d.context, false)
+}
+
+func (d *DigestMessage) ToJson() string {
+	content, err := json.MarshalIndent(d.Digest, "", "  ")
+	PanicOnError(err)
+	return string(content) + "\n"
+}
+
+// ToCsv returns the records of every monitor in a single csv table.
+func (d *DigestMessage) ToCsv() string {
+	b := strings.Builder{}
+	for i, report := range d.Digest.Reports {
+		message := ReportMessage{Report: report}
+		out := message.ToCsv()
+		if i > 0 {
+			// drop the repeated header row
+			out = out[strings.Index(out, "\n")+1:]
+		}
+		b.WriteString(out)
+	}
+	return b.String()
+}
+
+// Render returns the digest in the requested format.
+func (d *DigestMessage) Render(format string) (string, error) {
+	switch format {
+	case FormatText, "":
+		return d.ToText(), nil
+	case FormatHtml:
+		return d.ToHtml(), nil
+	case FormatJson:
+		return d.ToJson(), nil
+	case FormatCsv:
+		return d.ToCsv(), nil
+	case FormatMarkdown:
+		return d.ToMarkdown(), nil
+	}
+	return "", errors.New(fmt.Sprintf("unknown report format: %s", format))
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDigestMessage() *DigestMessage {
+	failing := testStoreMaster()
+	failing.Url = "https://markgemmill.com/api"
+	failing.StoreId = "api"
+	failing.Passes = append(failing.Passes, failing.Current)
+	failing.Current = StoreRecord{
+		Start:   time.Date(2022, 9, 3, 11, 15, 0, 0, time.UTC),
+		Last:    time.Date(2022, 9, 3, 13, 15, 0, 0, time.UTC),
+		Status:  FAIL,
+		Count:   24,
+		Message: "expecting status of 200, but received 502",
+	}
+
+	return &DigestMessage{
+		Stores: []*StoreMaster{testStoreMaster(), failing},
+		Options: ReportOptions{
+			Windows: []string{"7d"},
+			Until:   time.Date(2022, 9, 4, 0, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestDigestSummary(t *testing.T) {
+	message := testDigestMessage()
+	assert.Nil(t, message.Initialize())
+
+	summary := message.Digest.Summary
+	assert.Equal(t, 2, len(summary))
+	assert.Equal(t, PASS, summary[0].Status)
+	assert.Equal(t, 1, summary[0].Incidents)
+	assert.Equal(t, "1 hour", summary[0].WorstOutageText())
+	assert.Equal(t, FAIL, summary[1].Status)
+	assert.Equal(t, 2, summary[1].Incidents)
+	assert.Equal(t, "2 hours", summary[1].WorstOutageText())
+
+	assert.Equal(t, "URL CHECK DIGEST: 2 monitors, 1 failing", message.Subject())
+}
+
+func TestDigestRender(t *testing.T) {
+	message := testDigestMessage()
+	assert.Nil(t, message.Initialize())
+
+	out := message.ToText()
+	assert.Contains(t, out, "PASS https://markgemmill.com ")
+	assert.Contains(t, out, "FAIL https://markgemmill.com/api ")
+	assert.Contains(t, out, "worst outage 2 hours.")
+
+	out = message.ToHtml()
+	assert.Contains(t, out, "WORST OUTAGE")
+	assert.Contains(t, out, "https://markgemmill.com/api")
+
+	out, err := message.Render(FormatJson)
+	assert.Nil(t, err)
+	digest := Digest{}
+	assert.Nil(t, json.Unmarshal([]byte(out), &digest))
+	assert.Equal(t, 2, len(digest.Reports))
+
+	out, _ = message.Render(FormatCsv)
+	assert.Equal(t, 1, strings.Count(out, "url,status,"))
+	assert.Equal(t, 8, strings.Count(out, "\n"))
+}
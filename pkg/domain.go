@@ -10,4 +10,14 @@ type StoreRecord struct {
 	Count    int64     `json:"count"`
 	Status   string    `json:"status"`
 	Message  string    `json:"message"`
+	// Redirects is the redirect chain of the last check.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// Suppressed is true if an alert of the failure was suppressed during a
+	// quiet ignore period and has not been sent since.
+	Suppressed bool `json:"suppressed,omitempty"`
+	// Step is the failed step of the last check of a scenario.
+	Step string `json:"step,omitempty"`
+	// Failures are the failed assertions of the last check, see
+	// Result.Failures.
+	Failures []AssertionResult `json:"failures,omitempty"`
 }
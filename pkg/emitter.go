@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MetricTiming  = "ms"
+	MetricCounter = "c"
+	MetricGauge   = "g"
+)
+
+// MetricSample is a single value sent to a metrics collector.
+type MetricSample struct {
+	Name  string
+	Value float64
+	Kind  string
+	Tags  map[string]string
+}
+
+// MetricEmitter sends metric samples to a collector after each check.
+type MetricEmitter interface {
+	Emit(samples []MetricSample) error
+}
+
+func metricName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func sortedTags(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func mergeTags(tags ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, t := range tags {
+		for key, value := range t {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+/*
+StatsdEmitter sends samples as StatsD lines over udp, with DogStatsD style
+tags understood by Datadog, Telegraf and the Prometheus statsd_exporter:
+
+	pingu.check.latency:123|ms|#monitor:api,env:prod
+*/
+type StatsdEmitter struct {
+	Address string
+	Prefix  string
+	Tags    map[string]string
+}
+
+func NewStatsdEmitter(address, prefix string, tags map[string]string) *StatsdEmitter {
+	return &StatsdEmitter{Address: address, Prefix: prefix, Tags: tags}
+}
+
+func (e *StatsdEmitter) Emit(samples []MetricSample) error {
+	b := strings.Builder{}
+	for _, sample := range samples {
+		_, _ = fmt.Fprintf(&b, "%s:%s|%s", metricName(e.Prefix, sample.Name), formatValue(sample.Value), sample.Kind)
+		tags := mergeTags(e.Tags, sample.Tags)
+		for i, key := range sortedTags(tags) {
+			if i == 0 {
+				b.WriteString("|#")
+			} else {
+				b.WriteString(",")
+			}
+			_, _ = fmt.Fprintf(&b, "%s:%s", key, tags[key])
+		}
+		b.WriteString("\n")
+	}
+
+	conn, err := net.Dial("udp", e.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(b.String()))
+	return err
+}
+
+/*
+GraphiteEmitter sends samples as Graphite plaintext lines over tcp, using
+Graphite tagged series for the tags:
+
+	pingu.check.latency;env=prod;monitor=api 123 1664553600
+*/
+type GraphiteEmitter struct {
+	Address string
+	Prefix  string
+	Tags    map[string]string
+	Timeout time.Duration
+}
+
+func NewGraphiteEmitter(address, prefix string, tags map[string]string) *GraphiteEmitter {
+	return &GraphiteEmitter{Address: address, Prefix: prefix, Tags: tags, Timeout: 5 * time.Second}
+}
+
+func (e *GraphiteEmitter) Emit(samples []MetricSample) error {
+	now := time.Now().Unix()
+
+	b := strings.Builder{}
+	for _, sample := range samples {
+		b.WriteString(metricName(e.Prefix, sample.Name))
+		tags := mergeTags(e.Tags, sample.Tags)
+		for _, key := range sortedTags(tags) {
+			_, _ = fmt.Fprintf(&b, ";%s=%s", key, tags[key])
+		}
+		_, _ = fmt.Fprintf(&b, " %s %d\n", formatValue(sample.Value), now)
+	}
+
+	conn, err := net.DialTimeout("tcp", e.Address, e.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetWriteDeadline(time.Now().Add(e.Timeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write([]byte(b.String()))
+	return err
+}
+
+// emit sends the samples to every emitter. Failures are only reported, as
+// they should never fail the check itself.
+func emit(emitters []MetricEmitter, samples []MetricSample, console Logger) {
+	for _, emitter := range emitters {
+		err := emitter.Emit(samples)
+		if err != nil {
+			console.Debug("%s %s\n", Yellow("Metric emit failed:"), err)
+		}
+	}
+}
+
+// EmitCheck sends the latency and pass/fail counters of a completed check
+// to the emitters.
+func EmitCheck(emitters []MetricEmitter, result Result, store *Store, tags map[string]string, console Logger) {
+	if len(emitters) == 0 {
+		return
+	}
+
+	tags = mergeTags(map[string]string{"monitor": store.Name}, tags)
+
+	name := "check.fail"
+	if result.Pass {
+		name = "check.pass"
+	}
+
+	emit(emitters, []MetricSample{
+		{Name: "check.latency", Value: float64(result.Response.Timing.Total.Milliseconds()), Kind: MetricTiming, Tags: tags},
+		{Name: name, Value: 1, Kind: MetricCounter, Tags: tags},
+	}, console)
+}
+
+// EmitRetry sends the retry counter of a monitor to the emitters.
+func EmitRetry(emitters []MetricEmitter, storeId string, tags map[string]string, console Logger) {
+	if len(emitters) == 0 {
+		return
+	}
+
+	tags = mergeTags(map[string]string{"monitor": storeId}, tags)
+	emit(emitters, []MetricSample{
+		{Name: "check.retry", Value: 1, Kind: MetricCounter, Tags: tags},
+	}, console)
+}
@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdEmitter(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	emitter := NewStatsdEmitter(listener.LocalAddr().String(), "pingu", map[string]string{"env": "prod"})
+	err = emitter.Emit([]MetricSample{
+		{Name: "check.latency", Value: 123, Kind: MetricTiming, Tags: map[string]string{"monitor": "api"}},
+		{Name: "check.pass", Value: 1, Kind: MetricCounter},
+	})
+	assert.Nil(t, err)
+
+	buf := make([]byte, 1024)
+	_ = listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "pingu.check.latency:123|ms|#env:prod,monitor:api\npingu.check.pass:1|c|#env:prod\n", string(buf[:n]))
+}
+
+func TestGraphiteEmitter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	emitter := NewGraphiteEmitter(listener.Addr().String(), "pingu", nil)
+	err = emitter.Emit([]MetricSample{
+		{Name: "check.latency", Value: 45, Kind: MetricTiming, Tags: map[string]string{"monitor": "api", "env": "prod"}},
+		{Name: "check.fail", Value: 1, Kind: MetricCounter},
+	})
+	assert.Nil(t, err)
+
+	first := strings.Split(<-lines, " ")
+	assert.Equal(t, "pingu.check.latency;env=prod;monitor=api", first[0])
+	assert.Equal(t, "45", first[1])
+	second := strings.Split(<-lines, " ")
+	assert.Equal(t, "pingu.check.fail", second[0])
+	assert.Equal(t, "1", second[1])
+}
+
+func TestRunCheckEmitsMetrics(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("active"))
+	}))
+	defer target.Close()
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	spec := CheckSpec{
+		Url:             target.URL,
+		ExpectedStatus:  "200",
+		ExpectedContent: "active",
+		Emitters:        []MetricEmitter{NewStatsdEmitter(listener.LocalAddr().String(), "pingu", nil)},
+	}
+	_, _, err = RunCheck(context.Background(), spec, "target", map[string]string{"team": "web"}, NewRecorder(0))
+	assert.Nil(t, err)
+
+	buf := make([]byte, 1024)
+	_ = listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	assert.Nil(t, err)
+
+	out := string(buf[:n])
+	assert.Contains(t, out, "pingu.check.latency:")
+	assert.Contains(t, out, "|ms|#monitor:target,team:web\n")
+	assert.Contains(t, out, "pingu.check.pass:1|c|#monitor:target,team:web\n")
+}
+
+func TestExporterEmitsMetrics(t *testing.T) {
+	exporter, _ := testExporter(t)
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	// the exporter sends the metrics to the emitters of its own config
+	exporter.Config.Statsd = &MetricsConfig{Address: listener.LocalAddr().String(), Prefix: "pingu"}
+	exporter.Config.Monitors = exporter.Config.Monitors[:1]
+	exporter.emitters = exporter.Config.MetricEmitters()
+	exporter.CheckAll()
+
+	buf := make([]byte, 1024)
+	_ = listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	assert.Nil(t, err)
+	assert.Contains(t, string(buf[:n]), "pingu.check.pass:1|c|#monitor:up\n")
+}
@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// Exporter runs the configured monitors and exposes their metrics.
+type Exporter struct {
+	Config   *Config
+	Metrics  *Metrics
+	Pool     *Pool
+	client   *http.Client
+	emitters []MetricEmitter
+	console  Logger
+}
+
+func NewExporter(config *Config, console Logger) *Exporter {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.MaxPerHost > 0 {
+		transport.MaxConnsPerHost = config.MaxPerHost
+	}
+	return &Exporter{
+		Config:   config,
+		Metrics:  NewMetrics(),
+		Pool:     NewPool(config.Concurrency, config.MaxPerHost),
+		client:   &http.Client{Transport: transport},
+		emitters: config.MetricEmitters(),
+		console:  console,
+	}
+}
+
+// Check runs a single check of the monitor once the pool has a free slot
+// for it, then writes its output.
+func (e *Exporter) Check(monitor MonitorConfig) {
+	buffer := NewBuffer()
+	e.Pool.Do(urlHost(monitor.Url), func() { e.check(monitor, buffer) })
+	buffer.Flush(e.console)
+}
+
+// check runs a single check of the monitor, records its metrics and sends
+// an alert once the failures reach the monitor's alert threshold. Outside
+// the monitor's business hours the failure is held, and a digest of the
+// held failures is sent once they open. While the monitor is flapping a
+// single flapping alert is sent instead.
+func (e *Exporter) check(monitor MonitorConfig, console Logger) {
+	console = console.With(Fields{"monitor": monitor.StoreId(), "url": monitor.Url})
+	spec := monitor.CheckSpec()
+	spec.Client = e.client
+	spec.Emitters = e.emitters
+
+	now := time.Now()
+	maintenance, quiet := e.maintenance(monitor, now, console)
+	if maintenance != "" {
+		_, err := RecordMaintenance(context.Background(), spec, monitor.StoreName, maintenance, monitor.MaintCheck, console)
+		if err != nil {
+			console.Print("%s %s\n", Red("Check failed:"), err)
+		}
+		return
+	}
+
+	result, store, err := RetryCheck(context.Background(), spec, monitor.StoreName, monitor.Tags, monitor.Retry, console)
+	if err != nil {
+		console.Print("%s %s\n", Red("Check failed:"), err)
+		return
+	}
+	flap, _ := CheckFlapping(store, monitor.Flapping, now, console)
+	e.Metrics.RecordCheck(result, store)
+	if len(store.Data.Held) > 0 && e.Config.Email != nil && monitor.Hours.Open(now) {
+		e.heldDigest(store, console)
+	}
+
+	policy := AlertPolicy{Threshold: monitor.AlertThreshold, Email: e.Config.Email != nil, Quiet: quiet, Hours: monitor.Hours}
+	switch RouteAlert(store, flap, policy, now, console) {
+	case AlertFlapping:
+		console.Log(1, "alert.send", Fields{"reason": "flapping"}, Yellow("Sending Flapping Alert...\n"))
+		err = SendConfigFlappingAlert(e.Config.Email, monitor.Url, flap, &store.Data.Current, console)
+		if err != nil {
+			console.Print("%s %s\n", Red("Alert failed:"), err)
+			return
+		}
+		store.FlapAlertSent()
+		e.Metrics.RecordAlert(store.Url, store.Name)
+	case AlertFailure:
+		console.Log(1, "alert.send", nil, Yellow("Sending Email Alert...\n"))
+		err = SendConfigAlert(e.Config.Email, monitor.Url, &store.Data.Current, console)
+		if err != nil {
+			console.Print("%s %s\n", Red("Alert failed:"), err)
+			return
+		}
+		store.AlertSent()
+		e.Metrics.RecordAlert(store.Url, store.Name)
+	}
+}
+
+// heldDigest sends the digest of the failures held outside business hours.
+func (e *Exporter) heldDigest(store *Store, console Logger) {
+	console.Log(1, "digest.send", Fields{"held": len(store.Data.Held)}, Yellow("Sending digest of the failures held outside business hours...\n"))
+	err := SendConfigHeldDigest(e.Config.Email, store, console)
+	if err != nil {
+		console.Print("%s %s\n", Red("Digest failed:"), err)
+	}
+}
+
+// maintenance returns why the monitor is not checked at the given time, or
+// an empty string if it is checked, and whether its alerts are suppressed by
+// a quiet ignore period.
+func (e *Exporter) maintenance(monitor MonitorConfig, now time.Time, console Logger) (string, bool) {
+	quiet := false
+	for _, ignoreText := range monitor.IgnorePeriods {
+		if IsIgnorePeriodActive(ignoreText, now) {
+			console.Log(1, "check.ignored", Fields{"period": ignoreText}, "%s %s\n", Red("Ignore time period:"), Yellow(ignoreText))
+			if monitor.IgnoreMode != IgnoreQuiet {
+				return "ignore period: " + ignoreText, false
+			}
+			quiet = true
+			break
+		}
+	}
+
+	// read under the check lock, so a check of the monitor still running
+	// from the last interval is not read half way through
+	store := NewStore(monitor.Url, monitor.StoreName)
+	store.Lock()
+	store.Read()
+	store.Unlock()
+	if silence := store.Data.ActiveSilence(now); silence != nil {
+		console.Log(1, "check.silenced", Fields{"until": silence.End, "reason": silence.Reason}, "%s %s\n", Red("Silenced:"), Yellow(silence.String()))
+		return "silenced: " + silence.ReasonText(), false
+	}
+
+	return "", quiet
+}
+
+/*
+CheckAll runs a single check of every monitor, as many at once as the pool
+allows. The output of each check is written in the order of the monitors
+in the config, as soon as it and the checks before it are done.
+*/
+func (e *Exporter) CheckAll() {
+	done := make([]chan struct{}, len(e.Config.Monitors))
+	buffers := make([]*Buffer, len(e.Config.Monitors))
+	for i, monitor := range e.Config.Monitors {
+		done[i] = make(chan struct{})
+		buffers[i] = NewBuffer()
+		go func(i int, monitor MonitorConfig) {
+			defer close(done[i])
+			e.Pool.Do(urlHost(monitor.Url), func() { e.check(monitor, buffers[i]) })
+		}(i, monitor)
+	}
+
+	for i := range done {
+		<-done[i]
+		buffers[i].Flush(e.console)
+	}
+}
+
+// Run checks each monitor on its own interval until stop is closed.
+func (e *Exporter) Run(stop <-chan struct{}) {
+	wg := sync.WaitGroup{}
+	for _, monitor := range e.Config.Monitors {
+		wg.Add(1)
+		go func(monitor MonitorConfig) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(monitor.Interval.Duration)
+			defer ticker.Stop()
+
+			e.Check(monitor)
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					e.Check(monitor)
+				}
+			}
+		}(monitor)
+	}
+	wg.Wait()
+}
+
+// Handler serves the metrics at /metrics.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		err := e.Metrics.WriteOpenMetrics(w)
+		IgnoreOnError(err)
+	})
+	return mux
+}
+
+// ListenAndServe runs the monitors and serves their metrics until the server fails.
+func (e *Exporter) ListenAndServe(listen string) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go e.Run(stop)
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           e.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	e.console.Info("Serving metrics on %s/metrics\n", listen)
+	return server.ListenAndServe()
+}
+
+// WriteTextfile writes the metrics to the file for the node_exporter textfile
+// collector. The file is replaced atomically so the collector never reads a
+// partial file.
+func (e *Exporter) WriteTextfile(filename string) error {
+	tmp, err := afero.TempFile(fs, path.Dir(filename), "."+path.Base(filename))
+	if err != nil {
+		return err
+	}
+
+	err = e.Metrics.WritePrometheus(tmp)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = fs.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return fs.Rename(tmp.Name(), filename)
+}
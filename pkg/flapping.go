@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FLAPPING is the status of a monitor that keeps changing between PASS and FAIL.
+const FLAPPING = "FLAPPING"
+
+const (
+	DefaultFlapHigh   = 50.0
+	DefaultFlapLow    = 25.0
+	DefaultFlapWindow = 21
+)
+
+/*
+FlapPolicy sets when a monitor is flapping, from the percent state change
+of its last Window checks. Flapping starts once the state change reaches
+High and stops once it falls to Low. Zero values are the defaults of 50%,
+25% and 21 checks.
+*/
+type FlapPolicy struct {
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Window   int     `json:"window"`
+	Disabled bool    `json:"disabled"`
+}
+
+func (p *FlapPolicy) high() float64 {
+	if p.High == 0 {
+		return DefaultFlapHigh
+	}
+	return p.High
+}
+
+func (p *FlapPolicy) low() float64 {
+	if p.Low == 0 {
+		return DefaultFlapLow
+	}
+	return p.Low
+}
+
+func (p *FlapPolicy) window() int {
+	if p.Window == 0 {
+		return DefaultFlapWindow
+	}
+	return p.Window
+}
+
+func (p *FlapPolicy) Validate() error {
+	if p.High < 0 || p.High > 100 || p.Low < 0 || p.Low > 100 {
+		return errors.New("flapping thresholds must be between 0 and 100.")
+	}
+	if p.low() >= p.high() {
+		return errors.New(fmt.Sprintf("the low flapping threshold %s must be below the high threshold %s.", percentText(p.low()), percentText(p.high())))
+	}
+	if p.Window < 0 || p.Window == 1 || p.Window == 2 {
+		return errors.New(fmt.Sprintf("the flapping window of %d checks must be at least 3 checks.", p.Window))
+	}
+	return nil
+}
+
+func percentText(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
+
+/*
+PercentStateChange returns how often the states, oldest first, change as
+a percent of the changes possible. As in Nagios, later changes count for
+more: the weight of a change rises evenly from 0.8 for the oldest to 1.2
+for the newest, so a monitor that has settled down soon stops flapping.
+*/
+func PercentStateChange(states []string) float64 {
+	n := len(states)
+	if n < 2 {
+		return 0
+	}
+	total := 0.0
+	for i := 1; i < n; i++ {
+		if states[i] == states[i-1] {
+			continue
+		}
+		weight := 1.0
+		if n > 2 {
+			weight = 0.8 + 0.4*float64(i-1)/float64(n-2)
+		}
+		total += weight
+	}
+	return total * 100 / float64(n-1)
+}
+
+// RecentStates returns the PASS or FAIL status of the last n checks,
+// oldest first. Checks during maintenance are left out.
+func (s *StoreMaster) RecentStates(n int) []string {
+	records := make([]StoreRecord, 0, len(s.Passes)+len(s.Failures)+1)
+	for _, list := range [][]StoreRecord{s.Passes, s.Failures, {s.Current}} {
+		for _, record := range list {
+			if record.Status == PASS || record.Status == FAIL {
+				records = append(records, record)
+			}
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Start.Before(records[j].Start)
+	})
+
+	states := make([]string, 0, n)
+	for i := len(records) - 1; i >= 0 && len(states) < n; i-- {
+		for c := int64(0); c < records[i].Count && len(states) < n; c++ {
+			states = append(states, records[i].Status)
+		}
+	}
+	for i, j := 0, len(states)-1; i < j; i, j = i+1, j-1 {
+		states[i], states[j] = states[j], states[i]
+	}
+	return states
+}
+
+// StateChange returns the percent state change of the last window checks.
+// A short history is padded with its oldest state, so a new monitor is not
+// flapping after its first few changes.
+func (s *StoreMaster) StateChange(window int) float64 {
+	states := s.RecentStates(window)
+	if len(states) == 0 {
+		return 0
+	}
+	padded := make([]string, window-len(states), window)
+	for i := range padded {
+		padded[i] = states[0]
+	}
+	return PercentStateChange(append(padded, states...))
+}
+
+// FlapPeriod is a period during which the monitor was flapping. The End of
+// a period that has not ended is zero, and Notified is set once its
+// flapping alert is sent.
+type FlapPeriod struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Percent  float64   `json:"percent"`
+	Notified bool      `json:"notified,omitempty"`
+}
+
+// Ongoing returns true if the monitor is still flapping.
+func (f FlapPeriod) Ongoing() bool {
+	return f.End.IsZero()
+}
+
+// Overlaps returns true if the period overlaps the range.
+func (f FlapPeriod) Overlaps(from, to time.Time) bool {
+	return f.Start.Before(to) && (f.Ongoing() || f.End.After(from))
+}
+
+// PercentText returns the highest percent state change of the period.
+// Example: 62.5%
+func (f FlapPeriod) PercentText() string {
+	return strconv.FormatFloat(f.Percent, 'f', 1, 64) + "%"
+}
+
+func (f FlapPeriod) String() string {
+	end := "ongoing"
+	if !f.Ongoing() {
+		end = f.End.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s - %s %s state change", f.Start.Format("2006-01-02 15:04"), end, f.PercentText())
+}
+
+// ActiveFlap returns the flapping period that has not ended, or nil.
+func (s *StoreMaster) ActiveFlap() *FlapPeriod {
+	for i := range s.Flaps {
+		if s.Flaps[i].Ongoing() {
+			return &s.Flaps[i]
+		}
+	}
+	return nil
+}
+
+// FlapAlertSent marks the flapping alert of the active period as sent and
+// writes the store.
+func (s *Store) FlapAlertSent() {
+	flap := s.Data.ActiveFlap()
+	if flap == nil || flap.Notified {
+		return
+	}
+	flap.Notified = true
+	s.Write()
+}
+
+/*
+DetectFlapping starts or ends a flapping period from the state change of
+the recent checks, and writes the store if the period changed. It returns
+whether the monitor started or stopped flapping. A disabled policy ends
+any period left open.
+*/
+func (s *Store) DetectFlapping(policy FlapPolicy, now time.Time) (started, stopped bool) {
+	flap := s.Data.ActiveFlap()
+	if policy.Disabled {
+		if flap != nil {
+			flap.End = now
+			s.Write()
+		}
+		return false, flap != nil
+	}
+
+	percent := s.Data.StateChange(policy.window())
+	switch {
+	case flap == nil && percent >= policy.high():
+		s.Data.Flaps = append(s.Data.Flaps, FlapPeriod{Start: now, Percent: percent})
+		started = true
+	case flap != nil && percent <= policy.low():
+		flap.End = now
+		stopped = true
+	case flap != nil && percent > flap.Percent:
+		flap.Percent = percent
+	default:
+		return false, false
+	}
+	s.Write()
+	return started, stopped
+}
+
+// CheckFlapping detects whether the monitor is flapping after a check, logs
+// when it starts or stops, and returns the active flapping period and
+// whether it has just started.
+func CheckFlapping(store *Store, policy FlapPolicy, now time.Time, console Logger) (*FlapPeriod, bool) {
+	started, stopped := store.DetectFlapping(policy, now)
+	flap := store.Data.ActiveFlap()
+	if started {
+		console.Log(1, "check.flapping", Fields{"percent": flap.Percent}, "%s %s state change\n", Red("Flapping:"), Yellow(flap.PercentText()))
+	}
+	if stopped {
+		console.Log(1, "check.flapping_stopped", nil, Yellow("Flapping stopped.\n"))
+	}
+	return flap, started
+}
@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentStateChange(t *testing.T) {
+	assert.Equal(t, 0.0, PercentStateChange([]string{}))
+	assert.Equal(t, 0.0, PercentStateChange([]string{PASS, PASS, PASS}))
+	assert.Equal(t, 100.0, PercentStateChange([]string{PASS, FAIL}))
+	assert.InDelta(t, 100.0, PercentStateChange([]string{PASS, FAIL, PASS, FAIL, PASS}), 0.001)
+
+	// later changes count for more than earlier ones
+	early := PercentStateChange([]string{PASS, FAIL, FAIL, FAIL, FAIL})
+	late := PercentStateChange([]string{PASS, PASS, PASS, PASS, FAIL})
+	assert.InDelta(t, 20.0, early, 0.001)
+	assert.InDelta(t, 30.0, late, 0.001)
+}
+
+// flappingStore returns a store whose checks alternate between PASS and
+// FAIL after a long run of passes.
+func flappingStore(changes int) *StoreMaster {
+	store := NewStoreMaster("https://some.url.com", "flap")
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	store.Passes = []StoreRecord{{}, {Start: start, Last: start.Add(time.Hour), Status: PASS, Count: 60}}
+	status := FAIL
+	for i := 1; i <= changes; i++ {
+		record := StoreRecord{Start: start.Add(time.Hour + time.Duration(i)*time.Minute), Status: status, Count: 1}
+		if status == FAIL {
+			store.Failures = append(store.Failures, record)
+			status = PASS
+		} else {
+			store.Passes = append(store.Passes, record)
+			status = FAIL
+		}
+	}
+	store.Current = StoreRecord{Start: start.Add(2 * time.Hour), Status: status, Count: 1}
+	return store
+}
+
+func TestRecentStates(t *testing.T) {
+	store := flappingStore(3)
+	assert.Equal(t, []string{PASS, PASS, FAIL, PASS, FAIL, PASS}, store.RecentStates(6))
+	assert.Equal(t, 21, len(store.RecentStates(21)))
+
+	// maintenance is left out
+	store.Current.Status = MAINT
+	assert.Equal(t, []string{PASS, FAIL, PASS, FAIL}, store.RecentStates(4))
+
+	assert.Equal(t, []string{}, NewStoreMaster("https://some.url.com", "").RecentStates(21))
+}
+
+func TestStateChange(t *testing.T) {
+	steady := flappingStore(0)
+	steady.Current.Status = PASS
+	assert.Equal(t, 0.0, steady.StateChange(21))
+	assert.True(t, flappingStore(12).StateChange(21) >= DefaultFlapHigh)
+
+	// a short history is padded, so the first failure is not flapping
+	store := NewStoreMaster("https://some.url.com", "")
+	store.Passes = []StoreRecord{{Start: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Status: PASS, Count: 1}}
+	store.Current = StoreRecord{Start: time.Date(2026, 10, 1, 0, 1, 0, 0, time.UTC), Status: FAIL, Count: 1}
+	assert.InDelta(t, 6.0, store.StateChange(21), 0.001)
+}
+
+func TestFlapPolicyValidate(t *testing.T) {
+	assert.Nil(t, (&FlapPolicy{}).Validate())
+	assert.Nil(t, (&FlapPolicy{High: 40, Low: 20, Window: 11}).Validate())
+	assert.EqualError(t, (&FlapPolicy{High: 120}).Validate(), "flapping thresholds must be between 0 and 100.")
+	assert.EqualError(t, (&FlapPolicy{High: 20}).Validate(), "the low flapping threshold 25% must be below the high threshold 20%.")
+	assert.EqualError(t, (&FlapPolicy{Window: 2}).Validate(), "the flapping window of 2 checks must be at least 3 checks.")
+}
+
+func TestDetectFlapping(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	now := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	store := NewStore("https://some.url.com", "flap")
+	store.Data = flappingStore(12)
+
+	started, stopped := store.DetectFlapping(FlapPolicy{}, now)
+	assert.True(t, started)
+	assert.False(t, stopped)
+	flap := store.Data.ActiveFlap()
+	assert.Equal(t, now, flap.Start)
+	assert.True(t, flap.Ongoing())
+
+	// the period is written to the store
+	saved := NewStore("https://some.url.com", "flap")
+	saved.Read()
+	assert.Equal(t, 1, len(saved.Data.Flaps))
+
+	// still flapping
+	started, stopped = store.DetectFlapping(FlapPolicy{}, now.Add(time.Minute))
+	assert.False(t, started)
+	assert.False(t, stopped)
+
+	// the monitor settles down
+	store.Data.Current.Count = 20
+	started, stopped = store.DetectFlapping(FlapPolicy{}, now.Add(time.Hour))
+	assert.False(t, started)
+	assert.True(t, stopped)
+	assert.Nil(t, store.Data.ActiveFlap())
+	assert.Equal(t, now.Add(time.Hour), store.Data.Flaps[0].End)
+	assert.Equal(t, "2026-10-01 02:00 - 2026-10-01 03:00 "+store.Data.Flaps[0].PercentText()+" state change", store.Data.Flaps[0].String())
+
+	// a disabled policy never flaps
+	store.Data = flappingStore(12)
+	started, _ = store.DetectFlapping(FlapPolicy{Disabled: true}, now)
+	assert.False(t, started)
+	assert.Nil(t, store.Data.ActiveFlap())
+}
+
+func TestCheckFlapping(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	store := NewStore("https://some.url.com", "flap")
+	store.Data = flappingStore(12)
+
+	recorder := NewRecorder(1)
+	flap, started := CheckFlapping(store, FlapPolicy{}, time.Now(), recorder)
+	assert.NotNil(t, flap)
+	assert.True(t, started)
+	assert.Equal(t, "check.flapping", recorder.Events()[0].Event)
+
+	flap, started = CheckFlapping(store, FlapPolicy{}, time.Now(), recorder)
+	assert.NotNil(t, flap)
+	assert.False(t, started)
+}
+
+func TestComposeFlappingMessage(t *testing.T) {
+	flap := &FlapPeriod{Start: time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC), Percent: 62.5}
+	record := &StoreRecord{Status: FAIL, Count: 1}
+
+	assert.Equal(t, "URL CHECK FLAPPING: https://some.url.com", ComposeFlappingSubject("https://some.url.com"))
+	assert.Contains(t, ComposeFlappingTextMessage("https://some.url.com", flap, record), "STATE CHANGE:        62.5%")
+	html := ComposeFlappingHtmlMessage("https://some.url.com", flap, record)
+	assert.Contains(t, html, "2026-10-01 02:00:00")
+	assert.Contains(t, html, "62.5%")
+}
+
+func TestReportFlapping(t *testing.T) {
+	store := testStoreMaster()
+	store.Flaps = []FlapPeriod{
+		{Start: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC), End: time.Date(2022, 9, 1, 14, 0, 0, 0, time.UTC), Percent: 55},
+		{Start: time.Date(2022, 9, 3, 11, 0, 0, 0, time.UTC), Percent: 62.5},
+	}
+
+	message := ReportMessage{Store: store}
+	assert.Nil(t, message.Initialize())
+	assert.Equal(t, 2, len(message.Report.Flaps))
+	assert.Equal(t, 62.5, message.Report.Flapping.Percent)
+
+	out := message.ToText()
+	assert.Contains(t, out, "FLAPPING since 2022-09-03 11:00:00, 62.5% state change.")
+	assert.Contains(t, out, "2022-09-01 12:00:00 - 2022-09-01 14:00:00 55.0% state change")
+	assert.Contains(t, out, "2022-09-03 11:00:00 - ongoing 62.5% state change")
+	assert.Contains(t, message.ToHtml(), "FLAPPING since")
+	out, _ = message.Render(FormatMarkdown)
+	assert.Contains(t, out, "| 2022-09-03 11:00:00 | ongoing | 62.5% |")
+
+	// only the periods within the range are kept
+	report := NewReport(store, time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC))
+	report.Clip(Interval{Start: time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC)})
+	assert.Equal(t, 0, len(report.Flaps))
+	assert.Nil(t, report.Flapping)
+}
+
+func TestDigestFlapping(t *testing.T) {
+	message := testDigestMessage()
+	message.Stores[1].Flaps = []FlapPeriod{{Start: time.Date(2022, 9, 3, 11, 0, 0, 0, time.UTC), Percent: 62.5}}
+	assert.Nil(t, message.Initialize())
+
+	assert.Equal(t, FLAPPING, message.Digest.Summary[1].Status)
+	assert.Equal(t, "URL CHECK DIGEST: 2 monitors, 0 failing, 1 flapping", message.Subject())
+	out := message.ToText()
+	assert.Contains(t, out, "FLAPPING https://markgemmill.com/api ")
+	assert.Contains(t, out, "Flapping since 2022-09-03 11:00:00, 62.5% state change.")
+	assert.Contains(t, message.ToHtml(), `class="even FLAPPING"`)
+}
+
+func TestExporterFlapping(t *testing.T) {
+	exporter, _ := testExporter(t)
+	exporter.Config.Monitors[1].AlertThreshold = 1
+
+	store := NewStore(exporter.Config.Monitors[1].Url, "down")
+	store.Data = flappingStore(12)
+	store.Data.Url = exporter.Config.Monitors[1].Url
+	store.Write()
+	exporter.CheckAll()
+
+	store.Read()
+	assert.NotNil(t, store.Data.ActiveFlap())
+	// the alert of the failure is held until the monitor stops flapping
+	assert.True(t, store.Data.Current.Suppressed)
+	assert.True(t, exporter.Metrics.samples["down"].Flapping)
+	assert.False(t, exporter.Metrics.samples["up"].Flapping)
+}
+
+func TestExporterFlappingAlertHeld(t *testing.T) {
+	exporter, _ := testExporter(t)
+	email, smtp := testSmtpServer(t)
+	exporter.Config.Email = email
+	exporter.Config.Monitors = exporter.Config.Monitors[1:]
+
+	// business hours on a day other than today are closed
+	day := strings.ToUpper(time.Now().AddDate(0, 0, 2).Weekday().String()[:3])
+	closed, err := ParseBusinessHours(day + " 00:00 - 23:59")
+	assert.Nil(t, err)
+	exporter.Config.Monitors[0].Hours = closed
+
+	store := NewStore(exporter.Config.Monitors[0].Url, "down")
+	store.Data = flappingStore(12)
+	store.Data.Url = exporter.Config.Monitors[0].Url
+	store.Write()
+
+	exporter.CheckAll()
+	store.Read()
+	assert.NotNil(t, store.Data.ActiveFlap())
+	assert.False(t, store.Data.ActiveFlap().Notified)
+	assert.Equal(t, 0, len(smtp.Subjects()))
+
+	// the alert is sent once business hours open, and only once
+	exporter.Config.Monitors[0].Hours = nil
+	exporter.CheckAll()
+	exporter.CheckAll()
+	store.Read()
+	assert.True(t, store.Data.ActiveFlap().Notified)
+	assert.Equal(t, []string{ComposeFlappingSubject(store.Url)}, smtp.Subjects())
+}
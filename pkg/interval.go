@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval is a span of time between a Start and End time.
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (i Interval) Duration() time.Duration {
+	if i.End.Before(i.Start) {
+		return 0
+	}
+	return i.End.Sub(i.Start)
+}
+
+// Contains checks if the given time is within the interval, inclusive of both ends.
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && !t.After(i.End)
+}
+
+// Clip returns the portion of the interval that falls within the bounds,
+// and false if there is no overlap.
+func (i Interval) Clip(bounds Interval) (Interval, bool) {
+	clipped := i
+	if clipped.Start.Before(bounds.Start) {
+		clipped.Start = bounds.Start
+	}
+	if clipped.End.After(bounds.End) {
+		clipped.End = bounds.End
+	}
+	if !clipped.Start.Before(clipped.End) {
+		return Interval{}, false
+	}
+	return clipped, true
+}
+
+// Overlap returns the amount of time the two intervals share.
+func (i Interval) Overlap(other Interval) time.Duration {
+	clipped, ok := i.Clip(other)
+	if !ok {
+		return 0
+	}
+	return clipped.Duration()
+}
+
+// MergeIntervals sorts the intervals and joins any that overlap.
+func MergeIntervals(intervals []Interval) []Interval {
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	merged := make([]Interval, 0, len(sorted))
+	for _, interval := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
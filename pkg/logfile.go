@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"os"
+	"sync"
+)
+
+/*
+RotatingFile is a log file that is rotated once it grows past MaxSize bytes.
+The rotated files are renamed with a numeric suffix, pingu.log.1 being the
+most recent, and only MaxBackups of them are kept.
+*/
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	mu         sync.Mutex
+	file       afero.File
+	size       int64
+}
+
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := fs.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *RotatingFile) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", f.Path, n)
+}
+
+// rotate shifts the backups up by one, dropping the oldest, and reopens an
+// empty log file.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	if f.MaxBackups > 0 {
+		_ = fs.Remove(f.backupName(f.MaxBackups))
+		for n := f.MaxBackups - 1; n > 0; n-- {
+			_ = fs.Rename(f.backupName(n), f.backupName(n+1))
+		}
+		err = fs.Rename(f.Path, f.backupName(1))
+	} else {
+		err = fs.Remove(f.Path)
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,479 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+MaintenanceWindow is a span of time, once or recurring, during which a url
+is not checked and which is excluded from uptime. See ParseMaintenanceWindow.
+*/
+type MaintenanceWindow interface {
+	// Active returns true if the given time is within the window.
+	Active(given time.Time) bool
+	// Occurrences returns every instance of the window that overlaps the range.
+	Occurrences(from, to time.Time) []Interval
+}
+
+/*
+ParseMaintenanceWindow accepts a maintenance window in any of the forms:
+
+	SAT 22:00 - SUN 01:00
+	2026-11-03 01:00 - 2026-11-03 04:00
+	first SUN of the month 02:00 - 04:00
+	0 2 * * SUN for 2h
+
+The first is a weekly or daily TimePeriod, see ParseTimePeriod. The second
+is a one-off DateWindow, the third a MonthlyWindow and the last a cron
+expression with the duration of each window, see CronWindow.
+
+Every form can end with a time zone, such as America/Toronto.
+*/
+func ParseMaintenanceWindow(text string) (MaintenanceWindow, error) {
+	text = strings.TrimSpace(text)
+
+	switch {
+	case dateWindowRx.MatchString(text):
+		return ParseDateWindow(text)
+	case monthlyWindowRx.MatchString(text):
+		return ParseMonthlyWindow(text)
+	case cronWindowRx.MatchString(text):
+		return ParseCronWindow(text)
+	case timePeriodRx.MatchString(text):
+		b, e, err := ParseTimePeriod(text)
+		if err != nil {
+			return nil, err
+		}
+		return NewTimeout(b, e), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("'%s' is not a valid maintenance window.", text))
+}
+
+/*
+The ignore modes decide what happens to a check during an ignore period.
+IgnoreSkip records a MAINT status without checking the url (see
+RecordMaintenance), while IgnoreQuiet checks and records the url as usual
+but suppresses its alerts.
+*/
+const (
+	IgnoreSkip  = "skip"
+	IgnoreQuiet = "quiet"
+)
+
+// ValidateMaintenanceWindows returns the error of the first invalid window.
+func ValidateMaintenanceWindows(windows []string) error {
+	for _, text := range windows {
+		_, err := ParseMaintenanceWindow(text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeAt returns true if the given time is within an occurrence of the
+// window, inclusive of both ends.
+func activeAt(window MaintenanceWindow, given time.Time) bool {
+	for _, occurrence := range window.Occurrences(given.Add(-time.Nanosecond), given.Add(time.Nanosecond)) {
+		if occurrence.Contains(given) {
+			return true
+		}
+	}
+	return false
+}
+
+// submatches maps the named groups of the regex to their match in text.
+func submatches(re *regexp.Regexp, text string) map[string]string {
+	match := re.FindStringSubmatch(text)
+	mapping := make(map[string]string)
+	if match == nil {
+		return mapping
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			mapping[name] = match[i]
+		}
+	}
+	return mapping
+}
+
+/*
+DateWindow is a one-off maintenance window. Without a time zone the dates
+are in the local time of the server.
+*/
+type DateWindow struct {
+	Interval
+}
+
+var dateWindowRx = regexp.MustCompile(`^(?P<beginDate>\d{4}-\d\d-\d\d) (?P<beginTime>\d{1,2}:\d\d) ?- ?(?:(?P<endDate>\d{4}-\d\d-\d\d) )?(?P<endTime>\d{1,2}:\d\d)` + zonePattern + `$`)
+
+/*
+ParseDateWindow accepts a one-off window in the format below, where the end
+date can be omitted if it is the same as the start date:
+
+	YYYY-MM-DD HH:MM - YYYY-MM-DD HH:MM
+*/
+func ParseDateWindow(text string) (*DateWindow, error) {
+	match := submatches(dateWindowRx, text)
+	if len(match) == 0 {
+		return nil, errors.New(fmt.Sprintf("'%s' is not a valid date window.", text))
+	}
+
+	zone, err := loadZone(match["zone"])
+	if err != nil {
+		return nil, err
+	}
+	if zone == nil {
+		zone = time.Local
+	}
+
+	endDate := match["endDate"]
+	if endDate == "" {
+		endDate = match["beginDate"]
+	}
+
+	start, err := time.ParseInLocation("2006-01-02 15:04", match["beginDate"]+" "+match["beginTime"], zone)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("'%s' is an invalid date.", match["beginDate"]+" "+match["beginTime"]))
+	}
+	end, err := time.ParseInLocation("2006-01-02 15:04", endDate+" "+match["endTime"], zone)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("'%s' is an invalid date.", endDate+" "+match["endTime"]))
+	}
+	if !end.After(start) {
+		return nil, errors.New(fmt.Sprintf("'%s' ends before it starts.", text))
+	}
+
+	return &DateWindow{Interval{Start: start, End: end}}, nil
+}
+
+func (w *DateWindow) Active(given time.Time) bool {
+	return w.Contains(given)
+}
+
+func (w *DateWindow) Occurrences(from, to time.Time) []Interval {
+	if w.End.After(from) && w.Start.Before(to) {
+		return []Interval{w.Interval}
+	}
+	return []Interval{}
+}
+
+// LastWeek is the Week of a MonthlyWindow on the last weekday of the month.
+const LastWeek = -1
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+var weeks = map[string]int{
+	"FIRST":  1,
+	"SECOND": 2,
+	"THIRD":  3,
+	"FOURTH": 4,
+	"LAST":   LastWeek,
+}
+
+/*
+MonthlyWindow is a maintenance window on the nth weekday of every month,
+such as the first Sunday. Without a time zone the times are in the local
+time of the time checked.
+*/
+type MonthlyWindow struct {
+	Week    int
+	Weekday time.Weekday
+	Starts  DayTime
+	Ends    DayTime
+}
+
+var monthlyWindowRx = regexp.MustCompile(`^(?i:(?P<week>first|second|third|fourth|last) (?P<weekday>mon|tue|wed|thu|fri|sat|sun)[a-z]* of (?:the|every) month) (?P<beginHr>\d{1,2}):(?P<beginMin>\d\d) ?(?P<beginAM>AM|PM)? ?- ?(?P<endHr>\d{1,2}):(?P<endMin>\d\d) ?(?P<endAM>AM|PM)?` + zonePattern + `$`)
+
+/*
+ParseMonthlyWindow accepts a monthly window in the format:
+
+	WEEK DAY of the month HH:MM AM - HH:MM AM
+
+WEEK can be first, second, third, fourth or last and DAY is a day of the
+week, such as SUN or Sunday. AM can be AM, PM or omitted, as with
+ParseTimePeriod. A window that ends before it starts ends the next day.
+*/
+func ParseMonthlyWindow(text string) (*MonthlyWindow, error) {
+	match := submatches(monthlyWindowRx, text)
+	if len(match) == 0 {
+		return nil, errors.New(fmt.Sprintf("'%s' is not a valid monthly window.", text))
+	}
+
+	starts, err := NewDayTime("", match["beginHr"], match["beginMin"], match["beginAM"])
+	if err != nil {
+		return nil, err
+	}
+	ends, err := NewDayTime("", match["endHr"], match["endMin"], match["endAM"])
+	if err != nil {
+		return nil, err
+	}
+	zone, err := loadZone(match["zone"])
+	if err != nil {
+		return nil, err
+	}
+	starts.Zone = zone
+	ends.Zone = zone
+
+	window := MonthlyWindow{
+		Week:    weeks[strings.ToUpper(match["week"])],
+		Weekday: weekdays[strings.ToUpper(match["weekday"])],
+		Starts:  starts,
+		Ends:    ends,
+	}
+	return &window, nil
+}
+
+// day returns the date of the window in the month of the given time.
+func (w *MonthlyWindow) day(month time.Time) time.Time {
+	if w.Week == LastWeek {
+		last := time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, month.Location())
+		offset := (int(last.Weekday()) - int(w.Weekday) + 7) % 7
+		return last.AddDate(0, 0, -offset)
+	}
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	offset := (int(w.Weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(w.Week-1))
+}
+
+func (w *MonthlyWindow) Active(given time.Time) bool {
+	return activeAt(w, given)
+}
+
+func (w *MonthlyWindow) Occurrences(from, to time.Time) []Interval {
+	occurrences := make([]Interval, 0)
+
+	from = w.Starts.in(from)
+	month := time.Date(from.Year(), from.Month()-1, 1, 0, 0, 0, 0, from.Location())
+	for !month.After(to) {
+		day := w.day(month)
+		start := time.Date(day.Year(), day.Month(), day.Day(), w.Starts.twentyFour(), w.Starts.Minute, 0, 0, day.Location())
+		end := time.Date(day.Year(), day.Month(), day.Day(), w.Ends.twentyFour(), w.Ends.Minute, 0, 0, day.Location())
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if end.After(from) && start.Before(to) {
+			occurrences = append(occurrences, Interval{Start: start, End: end})
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+
+	return occurrences
+}
+
+// cronField is the set of values accepted by a field of a cron expression.
+type cronField struct {
+	values []bool
+	// any is true if the field starts with *, which matters when both the
+	// day of the month and day of the week are given.
+	any bool
+}
+
+func (f cronField) match(value int) bool {
+	return value >= 0 && value < len(f.values) && f.values[value]
+}
+
+var cronMonths = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var cronWeekdays = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+func parseCronValue(text string, names map[string]int) (int, error) {
+	if value, ok := names[strings.ToUpper(text)]; ok {
+		return value, nil
+	}
+	return strconv.Atoi(text)
+}
+
+/*
+parseCronField parses a field of a cron expression, which is a comma
+separated list of *, a value or a range, each with an optional /step. A
+range of a field with names, the months or days of the week, can wrap
+around past the last value.
+*/
+func parseCronField(text string, min, max int, names map[string]int) (cronField, error) {
+	field := cronField{values: make([]bool, max+1), any: strings.HasPrefix(text, "*")}
+	invalid := errors.New(fmt.Sprintf("'%s' is an invalid cron field, expecting values from %d to %d.", text, min, max))
+
+	for _, part := range strings.Split(text, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			value, err := strconv.Atoi(part[i+1:])
+			if err != nil || value < 1 {
+				return field, invalid
+			}
+			step = value
+			part = part[:i]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			value, err := parseCronValue(bounds[0], names)
+			if err != nil {
+				return field, invalid
+			}
+			low, high = value, value
+			if len(bounds) == 2 {
+				high, err = parseCronValue(bounds[1], names)
+				if err != nil {
+					return field, invalid
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+		if low < min || high > max {
+			return field, invalid
+		}
+		if low > high {
+			// a range of names, such as FRI-MON, wraps around to the start
+			if names == nil {
+				return field, invalid
+			}
+			high += len(names)
+		}
+
+		for value := low; value <= high; value += step {
+			if value > max {
+				field.values[(value-min)%len(names)+min] = true
+			} else {
+				field.values[value] = true
+			}
+		}
+	}
+
+	return field, nil
+}
+
+/*
+CronWindow is a maintenance window that starts at every time matching a
+cron expression and lasts for its Duration. Without a time zone the times
+are in the local time of the time checked.
+*/
+type CronWindow struct {
+	Expression string
+	Duration   time.Duration
+	Zone       *time.Location
+	minutes    cronField
+	hours      cronField
+	days       cronField
+	months     cronField
+	weekdays   cronField
+}
+
+var cronWindowRx = regexp.MustCompile(`^(?P<expression>\S+ \S+ \S+ \S+ \S+) for (?P<duration>\S+)` + zonePattern + `$`)
+
+/*
+ParseCronWindow accepts a standard five field cron expression, of the
+minute, hour, day of the month, month and day of the week, followed by the
+duration of the window:
+
+	0 2 * * SUN for 2h
+	30 1 1,15 * * for 90m
+	0 22 * * MON-FRI for 30m America/Toronto
+	0 22 * * FRI-SUN for 1d
+
+A range of days of the week or months can wrap around, such as FRI-MON or
+NOV-FEB, and the duration can be given in days (d) or weeks (w). As with cron, if both the day of the month and day of the week are
+restricted, a day matching either starts a window.
+*/
+func ParseCronWindow(text string) (*CronWindow, error) {
+	match := submatches(cronWindowRx, text)
+	if len(match) == 0 {
+		return nil, errors.New(fmt.Sprintf("'%s' is not a valid cron window.", text))
+	}
+
+	duration, err := ParseDays(match["duration"])
+	if err != nil || duration <= 0 {
+		return nil, errors.New(fmt.Sprintf("'%s' is an invalid window duration.", match["duration"]))
+	}
+	zone, err := loadZone(match["zone"])
+	if err != nil {
+		return nil, err
+	}
+
+	window := CronWindow{Expression: match["expression"], Duration: duration, Zone: zone}
+	fields := strings.Fields(window.Expression)
+	if window.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if window.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if window.days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if window.months, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if window.weekdays, err = parseCronField(fields[4], 0, 7, cronWeekdays); err != nil {
+		return nil, err
+	}
+	// both 0 and 7 are Sunday
+	window.weekdays.values[0] = window.weekdays.values[0] || window.weekdays.values[7]
+
+	return &window, nil
+}
+
+// matchDay returns true if a window can start on the day of the given time.
+func (w *CronWindow) matchDay(day time.Time) bool {
+	if !w.months.match(int(day.Month())) {
+		return false
+	}
+	if w.days.any || w.weekdays.any {
+		return w.days.match(day.Day()) && w.weekdays.match(int(day.Weekday()))
+	}
+	return w.days.match(day.Day()) || w.weekdays.match(int(day.Weekday()))
+}
+
+func (w *CronWindow) Active(given time.Time) bool {
+	return activeAt(w, given)
+}
+
+func (w *CronWindow) Occurrences(from, to time.Time) []Interval {
+	occurrences := make([]Interval, 0)
+
+	earliest := inZone(w.Zone, from.Add(-w.Duration))
+	day := time.Date(earliest.Year(), earliest.Month(), earliest.Day(), 0, 0, 0, 0, earliest.Location())
+	for !day.After(to) {
+		if w.matchDay(day) {
+			for hour := 0; hour < 24; hour++ {
+				if !w.hours.match(hour) {
+					continue
+				}
+				for minute := 0; minute < 60; minute++ {
+					if !w.minutes.match(minute) {
+						continue
+					}
+					start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+					end := start.Add(w.Duration)
+					if end.After(from) && start.Before(to) {
+						occurrences = append(occurrences, Interval{Start: start, End: end})
+					}
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return occurrences
+}
@@ -0,0 +1,187 @@
+package pkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	window, err := ParseMaintenanceWindow("SAT 22:00 - SUN 01:00")
+	assert.Nil(t, err)
+	assert.IsType(t, &TimePeriod{}, window)
+
+	window, err = ParseMaintenanceWindow("2026-11-03 01:00 - 2026-11-03 04:00")
+	assert.Nil(t, err)
+	assert.IsType(t, &DateWindow{}, window)
+
+	window, err = ParseMaintenanceWindow("first Sunday of the month 02:00 - 04:00")
+	assert.Nil(t, err)
+	assert.IsType(t, &MonthlyWindow{}, window)
+
+	window, err = ParseMaintenanceWindow("0 2 * * SUN for 2h")
+	assert.Nil(t, err)
+	assert.IsType(t, &CronWindow{}, window)
+
+	_, err = ParseMaintenanceWindow("every other tuesday")
+	assert.EqualError(t, err, "'every other tuesday' is not a valid maintenance window.")
+
+	_, err = ParseMaintenanceWindow("0 2 * * SUN for 2h Mars/Olympus")
+	assert.EqualError(t, err, "'Mars/Olympus' is an unknown time zone.")
+
+	assert.Nil(t, ValidateMaintenanceWindows([]string{"SAT 22:00 - SUN 01:00", "0 2 * * SUN for 2h"}))
+	assert.NotNil(t, ValidateMaintenanceWindows([]string{"SAT 22:00 - SUN 01:00", "0 2 * * SUN"}))
+}
+
+func TestDateWindow(t *testing.T) {
+	window, err := ParseDateWindow("2026-11-03 01:00 - 2026-11-03 04:00 UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC), window.Start)
+	assert.Equal(t, time.Date(2026, 11, 3, 4, 0, 0, 0, time.UTC), window.End)
+
+	assert.False(t, window.Active(time.Date(2026, 11, 3, 0, 59, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 11, 3, 4, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 11, 3, 4, 1, 0, 0, time.UTC)))
+
+	// the end date defaults to the start date
+	window, err = ParseDateWindow("2026-11-03 23:00 - 2026-11-04 01:00 UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Hour, window.Duration())
+	window, err = ParseDateWindow("2026-11-03 01:00 - 04:00 UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, 3*time.Hour, window.Duration())
+
+	assert.Equal(t, 1, len(window.Occurrences(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC))))
+	assert.Equal(t, 0, len(window.Occurrences(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))))
+
+	_, err = ParseDateWindow("2026-11-03 04:00 - 01:00")
+	assert.EqualError(t, err, "'2026-11-03 04:00 - 01:00' ends before it starts.")
+	_, err = ParseDateWindow("2026-13-03 01:00 - 04:00")
+	assert.NotNil(t, err)
+}
+
+func TestMonthlyWindow(t *testing.T) {
+	window, err := ParseMonthlyWindow("first Sunday of the month 02:00 - 04:00 UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, window.Week)
+	assert.Equal(t, time.Sunday, window.Weekday)
+
+	// the first Sunday of November 2026 is the 1st and of December the 6th
+	intervals := window.Occurrences(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, []Interval{
+		{Start: time.Date(2026, 11, 1, 2, 0, 0, 0, time.UTC), End: time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2026, 12, 6, 2, 0, 0, 0, time.UTC), End: time.Date(2026, 12, 6, 4, 0, 0, 0, time.UTC)},
+	}, intervals)
+
+	assert.True(t, window.Active(time.Date(2026, 12, 6, 3, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 12, 13, 3, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 12, 6, 4, 1, 0, 0, time.UTC)))
+
+	// the last Friday of February 2026 is the 27th, ending the next day
+	window, err = ParseMonthlyWindow("LAST FRI of every month 11:00 PM - 1:00 AM UTC")
+	assert.Nil(t, err)
+	assert.True(t, window.Active(time.Date(2026, 2, 27, 23, 30, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 2, 28, 0, 30, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 2, 20, 23, 30, 0, 0, time.UTC)))
+
+	// 10:00 in Toronto is 14:00 UTC after the spring forward on 2026-03-08
+	window, _ = ParseMonthlyWindow("third WED of the month 10:00 - 11:00 America/Toronto")
+	intervals = window.Occurrences(time.Date(2026, 3, 18, 14, 30, 0, 0, time.UTC), time.Date(2026, 3, 18, 15, 30, 0, 0, time.UTC))
+	assert.Equal(t, 1, len(intervals))
+	assert.Equal(t, time.Date(2026, 3, 18, 14, 0, 0, 0, time.UTC), intervals[0].Start.UTC())
+}
+
+func TestCronWindow(t *testing.T) {
+	window, err := ParseCronWindow("0 2 * * SUN for 2h UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, "0 2 * * SUN", window.Expression)
+	assert.Equal(t, 2*time.Hour, window.Duration)
+
+	// Sundays in November 2026 are the 1st, 8th, 15th, 22nd and 29th
+	intervals := window.Occurrences(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 5, len(intervals))
+	assert.Equal(t, Interval{Start: time.Date(2026, 11, 8, 2, 0, 0, 0, time.UTC), End: time.Date(2026, 11, 8, 4, 0, 0, 0, time.UTC)}, intervals[1])
+
+	assert.True(t, window.Active(time.Date(2026, 11, 8, 2, 0, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 11, 8, 4, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 11, 8, 4, 1, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 11, 9, 3, 0, 0, 0, time.UTC)))
+
+	// a window that started the day before
+	window, _ = ParseCronWindow("30 23 * * 6 for 2h UTC")
+	assert.True(t, window.Active(time.Date(2026, 11, 8, 1, 0, 0, 0, time.UTC)))
+
+	// ranges, steps, lists and names
+	window, err = ParseCronWindow("*/15 9-17 1,15 JAN-MAR * for 5m UTC")
+	assert.Nil(t, err)
+	assert.True(t, window.Active(time.Date(2026, 1, 15, 9, 45, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 1, 15, 9, 51, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 1, 16, 9, 45, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 4, 15, 9, 45, 0, 0, time.UTC)))
+
+	// a restricted day of the month and of the week match either
+	window, _ = ParseCronWindow("0 0 13 * FRI for 1h UTC")
+	assert.True(t, window.Active(time.Date(2026, 11, 13, 0, 30, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 11, 6, 0, 30, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 11, 7, 0, 30, 0, 0, time.UTC)))
+
+	// 7 is also Sunday
+	window, _ = ParseCronWindow("0 2 * * 1-7 for 1h UTC")
+	assert.True(t, window.Active(time.Date(2026, 11, 8, 2, 30, 0, 0, time.UTC)))
+
+	// a range of days of the week or months wraps around
+	window, err = ParseCronWindow("0 22 * * FRI-MON for 1h UTC")
+	assert.Nil(t, err)
+	for day := 6; day <= 12; day++ {
+		weekday := time.Date(2026, 11, day, 22, 30, 0, 0, time.UTC).Weekday()
+		expected := weekday == time.Friday || weekday == time.Saturday || weekday == time.Sunday || weekday == time.Monday
+		assert.Equal(t, expected, window.Active(time.Date(2026, 11, day, 22, 30, 0, 0, time.UTC)), weekday.String())
+	}
+	window, err = ParseCronWindow("0 22 * * SAT-SUN for 1h UTC")
+	assert.Nil(t, err)
+	assert.True(t, window.Active(time.Date(2026, 11, 7, 22, 30, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 11, 8, 22, 30, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 11, 9, 22, 30, 0, 0, time.UTC)))
+	window, err = ParseCronWindow("0 22 1 NOV-FEB * for 1h UTC")
+	assert.Nil(t, err)
+	assert.True(t, window.Active(time.Date(2027, 1, 1, 22, 30, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2027, 3, 1, 22, 30, 0, 0, time.UTC)))
+
+	// the duration can be given in days
+	window, err = ParseCronWindow("0 22 * * FRI for 1d UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, 24*time.Hour, window.Duration)
+	assert.True(t, window.Active(time.Date(2026, 11, 7, 21, 59, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 11, 7, 22, 1, 0, 0, time.UTC)))
+
+	for _, text := range []string{"60 2 * * SUN for 2h", "0 2 * * SUNDAY for 2h", "0 2 * * SUN for soon", "0 2 * * SUN for -1h", "0 5-2 * * * for 1h", "0 2 20-10 * * for 1h"} {
+		_, err = ParseCronWindow(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestCronWindowZone(t *testing.T) {
+	window, err := ParseCronWindow("0 22 * * MON-FRI for 30m America/Toronto")
+	assert.Nil(t, err)
+
+	// 22:00 is 03:00 UTC in standard time and 02:00 UTC in daylight saving time
+	assert.True(t, window.Active(time.Date(2026, 3, 3, 3, 15, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 3, 3, 2, 15, 0, 0, time.UTC)))
+	assert.True(t, window.Active(time.Date(2026, 3, 10, 2, 15, 0, 0, time.UTC)))
+	assert.False(t, window.Active(time.Date(2026, 3, 10, 3, 15, 0, 0, time.UTC)))
+}
+
+func TestIgnorePeriodIntervalsMaintenanceWindows(t *testing.T) {
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 11, 8, 0, 0, 0, 0, time.UTC)
+
+	intervals, err := IgnorePeriodIntervals([]string{"2026-11-03 01:00 - 04:00 UTC", "0 12 * * * for 1h UTC"}, from, to)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, len(intervals))
+	assert.True(t, IsIgnorePeriodActive("2026-11-03 01:00 - 04:00 UTC", time.Date(2026, 11, 3, 2, 0, 0, 0, time.UTC)))
+	assert.False(t, IsIgnorePeriodActive("0 2 * * SUN", time.Date(2026, 11, 8, 2, 0, 0, 0, time.UTC)))
+
+	_, err = IgnorePeriodIntervals([]string{"0 12 * * *"}, from, to)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,226 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenMetricsContentType is the content type of the /metrics response.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// MonitorSample holds the result of the last check of a monitor.
+type MonitorSample struct {
+	Url                 string
+	StoreId             string
+	Up                  bool
+	LastCheck           time.Time
+	StatusCode          int
+	Timing              UrlTiming
+	ConsecutiveFailures int64
+	CertExpiry          time.Time
+	Checks              map[string]int64
+	Alerts              int64
+	Flapping            bool
+}
+
+// Metrics collects the check results of every monitor for exposition.
+type Metrics struct {
+	mu      sync.Mutex
+	samples map[string]*MonitorSample
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		samples: make(map[string]*MonitorSample),
+	}
+}
+
+func (m *Metrics) sample(url, storeId string) *MonitorSample {
+	sample, ok := m.samples[storeId]
+	if !ok {
+		sample = &MonitorSample{
+			Url:     url,
+			StoreId: storeId,
+			Checks:  map[string]int64{PASS: 0, FAIL: 0},
+		}
+		m.samples[storeId] = sample
+	}
+	return sample
+}
+
+/*
+RecordCheck updates the monitor gauges from a completed check. The check
+counters are totalled from the store history, so they keep counting across
+separate runs of pingu.
+*/
+func (m *Metrics) RecordCheck(result Result, store *Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sample := m.sample(store.Url, store.Name)
+	sample.Up = result.Pass
+	sample.LastCheck = result.Checked
+	sample.StatusCode = result.Response.StatusCode
+	sample.Timing = result.Response.Timing
+	sample.CertExpiry = result.Response.CertExpiry
+
+	sample.Flapping = store.Data.ActiveFlap() != nil
+
+	sample.ConsecutiveFailures = 0
+	if store.Data.Current.Status == FAIL {
+		sample.ConsecutiveFailures = store.Data.Current.Count
+	}
+
+	sample.Checks[PASS] = 0
+	sample.Checks[FAIL] = 0
+	for _, records := range [][]StoreRecord{store.Data.Passes, store.Data.Failures, {store.Data.Current}} {
+		for _, record := range records {
+			if record.Status == PASS || record.Status == FAIL {
+				sample.Checks[record.Status] += record.Count
+			}
+		}
+	}
+}
+
+// RecordAlert increments the alert counter of the monitor.
+func (m *Metrics) RecordAlert(url, storeId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sample(url, storeId).Alerts += 1
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func labels(sample *MonitorSample, extra ...string) string {
+	b := strings.Builder{}
+	_, _ = fmt.Fprintf(&b, `monitor="%s",url="%s"`, escapeLabel(sample.StoreId), escapeLabel(sample.Url))
+	for i := 0; i+1 < len(extra); i += 2 {
+		_, _ = fmt.Fprintf(&b, `,%s="%s"`, extra[i], escapeLabel(extra[i+1]))
+	}
+	return "{" + b.String() + "}"
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// metricFamily is a single metric and how to read its value from a sample.
+type metricFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples func(sample *MonitorSample) [][2]string
+}
+
+var metricFamilies = []metricFamily{
+	{"pingu_up", "gauge", "Whether the last check of the monitor passed.", func(s *MonitorSample) [][2]string {
+		return [][2]string{{labels(s), boolValue(s.Up)}}
+	}},
+	{"pingu_last_check_timestamp_seconds", "gauge", "Unix time of the last check of the monitor.", func(s *MonitorSample) [][2]string {
+		return [][2]string{{labels(s), strconv.FormatInt(s.LastCheck.Unix(), 10)}}
+	}},
+	{"pingu_response_time_seconds", "gauge", "Duration of each phase of the last request.", func(s *MonitorSample) [][2]string {
+		return [][2]string{
+			{labels(s, "phase", "dns"), seconds(s.Timing.DNS)},
+			{labels(s, "phase", "connect"), seconds(s.Timing.Connect)},
+			{labels(s, "phase", "tls"), seconds(s.Timing.TLS)},
+			{labels(s, "phase", "first_byte"), seconds(s.Timing.FirstByte)},
+			{labels(s, "phase", "total"), seconds(s.Timing.Total)},
+		}
+	}},
+	{"pingu_status_code", "gauge", "Http status code of the last request, 0 if the url could not be fetched.", func(s *MonitorSample) [][2]string {
+		return [][2]string{{labels(s), strconv.Itoa(s.StatusCode)}}
+	}},
+	{"pingu_consecutive_failures", "gauge", "Number of consecutive failed checks of the monitor.", func(s *MonitorSample) [][2]string {
+		return [][2]string{{labels(s), strconv.FormatInt(s.ConsecutiveFailures, 10)}}
+	}},
+	{"pingu_flapping", "gauge", "Whether the status of the monitor keeps changing between pass and fail.", func(s *MonitorSample) [][2]string {
+		return [][2]string{{labels(s), boolValue(s.Flapping)}}
+	}},
+	{"pingu_cert_expiry_timestamp_seconds", "gauge", "Unix time the tls certificate of the monitor expires.", func(s *MonitorSample) [][2]string {
+		if s.CertExpiry.IsZero() {
+			return nil
+		}
+		return [][2]string{{labels(s), strconv.FormatInt(s.CertExpiry.Unix(), 10)}}
+	}},
+	{"pingu_checks", "counter", "Number of checks of the monitor by result.", func(s *MonitorSample) [][2]string {
+		return [][2]string{
+			{labels(s, "result", "pass"), strconv.FormatInt(s.Checks[PASS], 10)},
+			{labels(s, "result", "fail"), strconv.FormatInt(s.Checks[FAIL], 10)},
+		}
+	}},
+	{"pingu_alerts", "counter", "Number of alerts sent for the monitor.", func(s *MonitorSample) [][2]string {
+		return [][2]string{{labels(s), strconv.FormatInt(s.Alerts, 10)}}
+	}},
+}
+
+// WriteOpenMetrics writes every metric in the OpenMetrics text format.
+func (m *Metrics) WriteOpenMetrics(w io.Writer) error {
+	return m.write(w, true)
+}
+
+// WritePrometheus writes every metric in the Prometheus text format read by
+// the node_exporter textfile collector.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	return m.write(w, false)
+}
+
+/*
+write outputs the metrics as text. The two formats differ only in that
+OpenMetrics names counter families without the _total suffix and ends
+with an EOF marker.
+*/
+func (m *Metrics) write(w io.Writer, openMetrics bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.samples))
+	for id := range m.samples {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	b := strings.Builder{}
+	for _, family := range metricFamilies {
+		name := family.name
+		if family.kind == "counter" {
+			name += "_total"
+		}
+
+		familyName := name
+		if openMetrics {
+			familyName = family.name
+		}
+		_, _ = fmt.Fprintf(&b, "# TYPE %s %s\n", familyName, family.kind)
+		_, _ = fmt.Fprintf(&b, "# HELP %s %s\n", familyName, family.help)
+
+		for _, id := range ids {
+			for _, sample := range family.samples(m.samples[id]) {
+				_, _ = fmt.Fprintf(&b, "%s%s %s\n", name, sample[0], sample[1])
+			}
+		}
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
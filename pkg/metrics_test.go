@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"bytes"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testExporter(t *testing.T) (*Exporter, *httptest.Server) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	t.Cleanup(func() { fs = original })
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte("active"))
+	}))
+	t.Cleanup(target.Close)
+
+	config := &Config{
+		Monitors: []MonitorConfig{
+			{Url: target.URL + "/up", StoreName: "up", ExpectedStatus: "200"},
+			{Url: target.URL + "/down", StoreName: "down", ExpectedStatus: "200"},
+		},
+	}
+	return NewExporter(config, &Console{Verbosity: -1}), target
+}
+
+func TestExporterMetrics(t *testing.T) {
+	exporter, target := testExporter(t)
+	exporter.CheckAll()
+	exporter.CheckAll()
+
+	b := bytes.Buffer{}
+	assert.Nil(t, exporter.Metrics.WriteOpenMetrics(&b))
+	out := b.String()
+
+	assert.Contains(t, out, "# TYPE pingu_up gauge\n")
+	assert.Contains(t, out, `pingu_up{monitor="up",url="`+target.URL+`/up"} 1`)
+	assert.Contains(t, out, `pingu_up{monitor="down",url="`+target.URL+`/down"} 0`)
+	assert.Contains(t, out, `pingu_status_code{monitor="down",url="`+target.URL+`/down"} 502`)
+	assert.Contains(t, out, `pingu_consecutive_failures{monitor="down",url="`+target.URL+`/down"} 2`)
+	assert.Contains(t, out, `pingu_response_time_seconds{monitor="up",url="`+target.URL+`/up",phase="total"} `)
+	assert.Contains(t, out, "# TYPE pingu_checks counter\n")
+	assert.Contains(t, out, `pingu_checks_total{monitor="up",url="`+target.URL+`/up",result="pass"} 2`)
+	assert.Contains(t, out, `pingu_alerts_total{monitor="down",url="`+target.URL+`/down"} 0`)
+	assert.NotContains(t, out, "pingu_cert_expiry_timestamp_seconds{")
+	assert.True(t, strings.HasSuffix(out, "# EOF\n"))
+}
+
+func TestExporterHandler(t *testing.T) {
+	exporter, _ := testExporter(t)
+	exporter.CheckAll()
+
+	server := httptest.NewServer(exporter.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	assert.Nil(t, err)
+	assert.Equal(t, OpenMetricsContentType, resp.Header.Get("Content-Type"))
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "pingu_up{")
+}
+
+func TestExporterWriteTextfile(t *testing.T) {
+	exporter, _ := testExporter(t)
+	exporter.CheckAll()
+
+	_ = fs.MkdirAll("/textfile", 0777)
+	assert.Nil(t, exporter.WriteTextfile("/textfile/pingu.prom"))
+
+	content, err := afero.ReadFile(fs, "/textfile/pingu.prom")
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "# TYPE pingu_checks_total counter\n")
+	assert.NotContains(t, string(content), "# EOF")
+
+	files, _ := afero.ReadDir(fs, "/textfile")
+	assert.Equal(t, 1, len(files))
+}
+
+func TestExporterQuietIgnorePeriod(t *testing.T) {
+	exporter, _ := testExporter(t)
+	// a cron window active every minute is always active
+	exporter.Config.Monitors[1].IgnorePeriods = []string{"* * * * * for 1m"}
+	exporter.Config.Monitors[1].IgnoreMode = IgnoreQuiet
+	exporter.Config.Monitors[1].AlertThreshold = 3
+	exporter.CheckAll()
+
+	store := NewStore(exporter.Config.Monitors[1].Url, "down")
+	store.Read()
+	assert.Equal(t, FAIL, store.Data.Current.Status)
+	assert.True(t, store.Data.Current.Suppressed)
+	assert.True(t, AlertDue(&store.Data.Current, 3))
+}
+
+func TestExporterBusinessHours(t *testing.T) {
+	exporter, _ := testExporter(t)
+	email, smtp := testSmtpServer(t)
+	exporter.Config.Email = email
+	// business hours closed by a holiday that never ends
+	hours, _ := ParseBusinessHours("SUN-SAT 00:00 - 23:59 UTC")
+	hours.Holidays = &HolidayCalendar{Holidays: []Holiday{{Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)}}}
+	exporter.Config.Monitors[1].Hours = hours
+	exporter.CheckAll()
+
+	store := NewStore(exporter.Config.Monitors[1].Url, "down")
+	store.Read()
+	assert.True(t, store.Data.Current.Suppressed)
+	assert.Equal(t, 1, len(store.Data.Held))
+
+	// the same failure is held once
+	exporter.CheckAll()
+	store.Read()
+	assert.Equal(t, 1, len(store.Data.Held))
+	assert.Equal(t, int64(2), store.Data.Held[0].Count)
+	assert.Equal(t, 0, len(smtp.Subjects()))
+
+	b := bytes.Buffer{}
+	assert.Nil(t, exporter.Metrics.WriteOpenMetrics(&b))
+	assert.Contains(t, b.String(), `pingu_alerts_total{monitor="down",url="`+exporter.Config.Monitors[1].Url+`"} 0`)
+
+	// once business hours open the digest of the held failures is sent,
+	// followed by the alert of the failure that is still failing
+	exporter.Config.Monitors[1].Hours = nil
+	exporter.CheckAll()
+	store.Read()
+	assert.Equal(t, 0, len(store.Data.Held))
+	assert.Equal(t, []string{"URL CHECK DIGEST: 1 monitors, 1 failing", ComposeAlertSubject(store.Url)}, smtp.Subjects())
+}
+
+func TestExporterCheckAllOrder(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(80 * time.Millisecond)
+		}
+	}))
+	defer target.Close()
+
+	config := &Config{
+		Concurrency: 4,
+		Monitors: []MonitorConfig{
+			{Url: target.URL + "/slow", StoreName: "slow", ExpectedStatus: "200"},
+			{Url: target.URL + "/fast", StoreName: "fast", ExpectedStatus: "200"},
+			{Url: target.URL + "/slow?again", StoreName: "slower", ExpectedStatus: "200"},
+		},
+	}
+	recorder := NewRecorder(0)
+	exporter := NewExporter(config, recorder)
+
+	// the checks run at once, but are reported in the order of the config
+	start := time.Now()
+	exporter.CheckAll()
+	assert.True(t, time.Since(start) < 160*time.Millisecond)
+	monitors := make([]interface{}, 0)
+	for _, event := range recorder.Events() {
+		if event.Event == "check.pass" {
+			monitors = append(monitors, event.Fields["monitor"])
+		}
+	}
+	assert.Equal(t, []interface{}{"slow", "fast", "slower"}, monitors)
+
+	// a single slot checks one monitor at a time
+	config.Concurrency = 1
+	exporter = NewExporter(config, recorder)
+	start = time.Now()
+	exporter.CheckAll()
+	assert.True(t, time.Since(start) >= 160*time.Millisecond)
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"net/url"
+	"sync"
+)
+
+// DefaultConcurrency is the number of monitors checked at once.
+const DefaultConcurrency = 4
+
+// DefaultMaxPerHost is the number of monitors of a host checked at once.
+const DefaultMaxPerHost = 2
+
+/*
+Pool bounds the number of checks run at once, both overall and for each
+host, so a slow url does not hold up the others while a shared backend is
+not sent more than MaxPerHost checks at a time. A MaxPerHost of 0 does not
+limit the checks of a host.
+*/
+type Pool struct {
+	Concurrency int
+	MaxPerHost  int
+	slots       chan struct{}
+	mu          sync.Mutex
+	hosts       map[string]chan struct{}
+}
+
+func NewPool(concurrency, maxPerHost int) *Pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Pool{
+		Concurrency: concurrency,
+		MaxPerHost:  maxPerHost,
+		slots:       make(chan struct{}, concurrency),
+		hosts:       make(map[string]chan struct{}),
+	}
+}
+
+// host returns the slots of the host, or nil if hosts are not limited.
+func (p *Pool) host(name string) chan struct{} {
+	if p.MaxPerHost < 1 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	slots, ok := p.hosts[name]
+	if !ok {
+		slots = make(chan struct{}, p.MaxPerHost)
+		p.hosts[name] = slots
+	}
+	return slots
+}
+
+// Do runs fn once a slot of the host and then a slot of the pool are free.
+func (p *Pool) Do(host string, fn func()) {
+	if slots := p.host(host); slots != nil {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+	}
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+	fn()
+}
+
+// urlHost returns the host name of the url, which is the key of its host limit.
+func urlHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolPeak runs the hosts through the pool and returns the most checks run
+// at once, overall and of the host "a".
+func poolPeak(pool *Pool, hosts []string) (int, int) {
+	mu := sync.Mutex{}
+	running := map[string]int{}
+	total, peak, peakA := 0, 0, 0
+
+	wg := sync.WaitGroup{}
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			pool.Do(host, func() {
+				mu.Lock()
+				total += 1
+				running[host] += 1
+				if total > peak {
+					peak = total
+				}
+				if running["a"] > peakA {
+					peakA = running["a"]
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				total -= 1
+				running[host] -= 1
+				mu.Unlock()
+			})
+		}(host)
+	}
+	wg.Wait()
+	return peak, peakA
+}
+
+func TestPool(t *testing.T) {
+	hosts := []string{"a", "a", "a", "a", "a", "a", "b", "c", "d", "e", "f", "g"}
+
+	peak, peakA := poolPeak(NewPool(3, 0), hosts)
+	assert.True(t, peak <= 3)
+	assert.True(t, peakA <= 3)
+
+	peak, peakA = poolPeak(NewPool(4, 1), hosts)
+	assert.True(t, peak <= 4)
+	assert.Equal(t, 1, peakA)
+
+	assert.Equal(t, 1, NewPool(0, 0).Concurrency)
+}
+
+func TestUrlHost(t *testing.T) {
+	assert.Equal(t, "some.url.com", urlHost("https://some.url.com:8443/status"))
+	assert.Equal(t, "some.url.com", urlHost("http://some.url.com/api"))
+}
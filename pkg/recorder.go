@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LogEvent is a single event captured by a Recorder.
+type LogEvent struct {
+	Verbosity int
+	Event     string
+	Fields    Fields
+	Message   string
+	Indent    int
+}
+
+type recording struct {
+	mu     sync.Mutex
+	events []LogEvent
+}
+
+/*
+Recorder is a Logger that keeps every event in memory instead of writing
+it, so tests can assert on the output of a check. Loggers created with
+With record into the same list.
+*/
+type Recorder struct {
+	Verbosity    int
+	IndentAmount int
+	Fields       Fields
+	recording    *recording
+}
+
+func NewRecorder(verbosity int) *Recorder {
+	return &Recorder{
+		Verbosity: verbosity,
+		recording: &recording{},
+	}
+}
+
+func (r *Recorder) With(fields Fields) Logger {
+	child := *r
+	child.IndentAmount = 0
+	child.Fields = Fields{}
+	for key, value := range r.Fields {
+		child.Fields[key] = value
+	}
+	for key, value := range fields {
+		child.Fields[key] = value
+	}
+	return &child
+}
+
+func (r *Recorder) Indent() {
+	r.IndentAmount += 1
+}
+
+func (r *Recorder) Dedent() {
+	r.IndentAmount -= 1
+	if r.IndentAmount < 0 {
+		r.IndentAmount = 0
+	}
+}
+
+// Log records the event, with its colour codes removed, if the verbosity allows.
+func (r *Recorder) Log(verbosity int, event string, fields Fields, message string, opt ...interface{}) {
+	if r.Verbosity < verbosity {
+		return
+	}
+
+	record := Fields{}
+	for key, value := range r.Fields {
+		record[key] = value
+	}
+	for key, value := range fields {
+		record[key] = value
+	}
+
+	r.recording.mu.Lock()
+	defer r.recording.mu.Unlock()
+	r.recording.events = append(r.recording.events, LogEvent{
+		Verbosity: verbosity,
+		Event:     event,
+		Fields:    record,
+		Message:   StripAnsi(fmt.Sprintf(message, opt...)),
+		Indent:    r.IndentAmount,
+	})
+}
+
+func (r *Recorder) Print(message string, opt ...interface{}) {
+	r.Log(0, "message", nil, message, opt...)
+}
+
+func (r *Recorder) Info(message string, opt ...interface{}) {
+	r.Log(1, "message", nil, message, opt...)
+}
+
+func (r *Recorder) Debug(message string, opt ...interface{}) {
+	r.Log(2, "message", nil, message, opt...)
+}
+
+func (r *Recorder) Trace(message string, opt ...interface{}) {
+	r.Log(3, "message", nil, message, opt...)
+}
+
+// Events returns a copy of the recorded events.
+func (r *Recorder) Events() []LogEvent {
+	r.recording.mu.Lock()
+	defer r.recording.mu.Unlock()
+	return append([]LogEvent{}, r.recording.events...)
+}
+
+// Output returns the recorded messages as the text console would print them.
+func (r *Recorder) Output() string {
+	b := strings.Builder{}
+	for _, event := range r.Events() {
+		b.WriteString(strings.Repeat("  ", event.Indent))
+		b.WriteString(event.Message)
+	}
+	return b.String()
+}
@@ -2,10 +2,14 @@ package pkg
 
 import (
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/flosch/pongo2/v6"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -54,63 +58,314 @@ func DurationString(d time.Duration) string {
 	return b.String()
 }
 
-func StoreRecordStatusReport(record *StoreRecord) string {
+// ReportRecord is a single status span of the report model.
+type ReportRecord struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration"`
+	Count    int64     `json:"count"`
+	Status   string    `json:"status"`
+	Message  string    `json:"message"`
+}
+
+func NewReportRecord(record *StoreRecord) ReportRecord {
+	return ReportRecord{
+		Start:    record.Start,
+		End:      record.Last,
+		Duration: record.Last.Sub(record.Start).Seconds(),
+		Count:    record.Count,
+		Status:   record.Status,
+		Message:  record.Message,
+	}
+}
+
+// StatusText returns the PASSING/FAILING/MAINTENANCE description of the record status.
+func (r ReportRecord) StatusText() string {
+	if r.Status == PASS {
+		return "PASSING"
+	}
+	if r.Status == MAINT {
+		return "MAINTENANCE"
+	}
+	return "FAILING"
+}
+
+// DurationText returns the human-readable duration of the record.
+func (r ReportRecord) DurationText() string {
+	return DurationString(time.Duration(r.Duration * float64(time.Second)))
+}
+
+func (r ReportRecord) Summary() string {
 	/*
 		2020-09-24 10:34:00  PASSING 245 checks for last 23 days 12 hours and 5 minutes.
 		2020-09-01 12:13:00  FAIL     10 checks for 1 hour and 23 minutes.
 	*/
-	const report = `{{ .Record.Last.Format "2006-01-02 15:04:05" }} {{ .Status }} {{ .Record.Count }} checks for {{ .Duration }}.`
+	const report = `{{ .End.Format "2006-01-02 15:04:05" }} {{ .StatusText }} {{ .Count }} checks for {{ .DurationText }}.`
 	tmpl := template.Must(template.New("record-status").Parse(report))
 
-	status := "FAILING"
-	if record.Status == PASS {
-		status = "PASSING"
-	}
-
-	d := record.Last.Sub(record.Start)
-
-	data := struct {
-		Record   *StoreRecord
-		Status   string
-		Duration string
-	}{Record: record, Status: status, Duration: DurationString(d)}
-
 	var b bytes.Buffer
-	err := tmpl.Execute(&b, data)
+	err := tmpl.Execute(&b, r)
 	PanicOnError(err)
 
 	return b.String()
 }
 
-// ReportMessage creates an html and text report of the data store.
-type ReportMessage struct {
-	Store   *StoreMaster
-	context pongo2.Context
+func StoreRecordStatusReport(record *StoreRecord) string {
+	return NewReportRecord(record).Summary()
 }
 
-func (r *ReportMessage) Initialize() {
-
-	current := StoreRecordStatusReport(&r.Store.Current)
+// Report is the model shared by all the report renderers.
+type Report struct {
+	Url       string         `json:"url"`
+	StoreId   string         `json:"store-id"`
+	Generated time.Time      `json:"generated"`
+	Range     *Interval      `json:"range,omitempty"`
+	Current   ReportRecord   `json:"current"`
+	History   []ReportRecord `json:"history"`
+	Uptime    []UptimeStats  `json:"uptime"`
+	GroupBy   string         `json:"group-by,omitempty"`
+	Periods   []UptimeStats  `json:"periods,omitempty"`
+	Silences  []Silence      `json:"silences,omitempty"`
+	Flaps     []FlapPeriod   `json:"flaps,omitempty"`
+	Flapping  *FlapPeriod    `json:"flapping,omitempty"`
+}
 
+// NewReport builds the report model from the store data. Records without a
+// status (i.e. the empty record stashed on the first save) are dropped.
+func NewReport(store *StoreMaster, generated time.Time) *Report {
 	history := make([]StoreRecord, 0)
-	history = append(history, r.Store.Passes...)
-	history = append(history, r.Store.Failures...)
+	history = append(history, store.Passes...)
+	history = append(history, store.Failures...)
+	history = append(history, store.Maintenance...)
 
 	sort.Slice(history, func(i, j int) bool {
 		return history[i].Last.Before(history[j].Last)
 	})
 
-	var hist []string
+	report := Report{
+		Url:       store.Url,
+		StoreId:   store.StoreId,
+		Generated: generated,
+		Current:   NewReportRecord(&store.Current),
+		History:   make([]ReportRecord, 0),
+		Uptime:    make([]UptimeStats, 0),
+		Silences:  append([]Silence{}, store.Silences...),
+		Flaps:     append([]FlapPeriod{}, store.Flaps...),
+	}
+	if flap := store.ActiveFlap(); flap != nil {
+		flapping := *flap
+		report.Flapping = &flapping
+	}
 
 	for _, record := range history {
-		hist = append(hist, StoreRecordStatusReport(&record))
+		if record.Status == "" {
+			continue
+		}
+		report.History = append(report.History, NewReportRecord(&record))
+	}
+
+	return &report
+}
+
+// Records returns the history and current records in chronological order.
+func (r *Report) Records() []ReportRecord {
+	records := make([]ReportRecord, 0, len(r.History)+1)
+	records = append(records, r.History...)
+	if r.Current.Status != "" {
+		records = append(records, r.Current)
+	}
+	return records
+}
+
+/*
+Clip limits the report to records whose span overlaps the bounds, and
+trims their start and end times to the bounds. A record's span runs
+until the next record starts, so a status that began before the bounds
+is still counted for the time it held within them.
+*/
+func (r *Report) Clip(bounds Interval) {
+	records := r.Records()
+	spans := statusSpans(records)
+
+	r.Range = &bounds
+	r.History = make([]ReportRecord, 0, len(records))
+	current := ReportRecord{}
+
+	for i, record := range records {
+		span, ok := Interval{Start: spans[i].Start, End: spans[i].End}.Clip(bounds)
+		if !ok && !(spans[i].Start.Equal(spans[i].End) && bounds.Contains(spans[i].Start)) {
+			continue
+		}
+		if !ok {
+			span = Interval{Start: spans[i].Start, End: spans[i].End}
+		}
+		record.Start = span.Start
+		if record.End.After(span.End) {
+			record.End = span.End
+		}
+		if record.End.Before(record.Start) {
+			record.End = record.Start
+		}
+		record.Duration = record.End.Sub(record.Start).Seconds()
+
+		if i == len(records)-1 && r.Current.Status != "" {
+			current = record
+			continue
+		}
+		r.History = append(r.History, record)
+	}
+
+	r.Current = current
+
+	silences := make([]Silence, 0, len(r.Silences))
+	for _, silence := range r.Silences {
+		if len(silence.Occurrences(bounds.Start, bounds.End)) > 0 {
+			silences = append(silences, silence)
+		}
+	}
+	r.Silences = silences
+
+	flaps := make([]FlapPeriod, 0, len(r.Flaps))
+	for _, flap := range r.Flaps {
+		if flap.Overlaps(bounds.Start, bounds.End) {
+			flaps = append(flaps, flap)
+		}
+	}
+	r.Flaps = flaps
+	if r.Flapping != nil && !r.Flapping.Start.Before(bounds.End) {
+		r.Flapping = nil
+	}
+}
+
+const (
+	FormatText     = "text"
+	FormatHtml     = "html"
+	FormatJson     = "json"
+	FormatCsv      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// ReportOptions controls the range and calculations included in the report.
+type ReportOptions struct {
+	Windows       []string
+	SlaTarget     float64
+	IgnorePeriods []string
+	Since         time.Time
+	Until         time.Time
+	GroupBy       string
+}
+
+// end returns the time the report runs up to.
+func (r *Report) end(options ReportOptions) time.Time {
+	if !options.Until.IsZero() {
+		return options.Until
+	}
+	return r.Generated
+}
+
+// start returns the time the report runs from.
+func (r *Report) start(options ReportOptions) time.Time {
+	if !options.Since.IsZero() {
+		return options.Since
+	}
+	records := r.Records()
+	if len(records) == 0 {
+		return r.end(options)
+	}
+	return records[0].Start
+}
+
+// calculate adds the uptime statistics of the window to the list,
+// excluding any time covered by the ignore periods.
+func (r *Report) calculate(stats []UptimeStats, window UptimeWindow, options ReportOptions) ([]UptimeStats, error) {
+	excluded, err := IgnorePeriodIntervals(options.IgnorePeriods, window.Start, window.End)
+	if err != nil {
+		return stats, err
+	}
+	return append(stats, CalculateUptime(r.Records(), window, excluded, options.SlaTarget)), nil
+}
+
+// CalculateUptime adds the uptime statistics for each window to the report.
+func (r *Report) CalculateUptime(options ReportOptions) error {
+	for _, w := range options.Windows {
+		window, err := ParseUptimeWindow(w, r.end(options))
+		if err != nil {
+			return err
+		}
+		r.Uptime, err = r.calculate(r.Uptime, window, options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CalculatePeriods adds the uptime statistics for each day, week or month
+// of the report range.
+func (r *Report) CalculatePeriods(options ReportOptions) error {
+	if options.GroupBy == "" {
+		return nil
+	}
+
+	periods, err := GroupPeriods(options.GroupBy, Interval{Start: r.start(options), End: r.end(options)})
+	if err != nil {
+		return err
+	}
+
+	r.GroupBy = options.GroupBy
+	r.Periods = make([]UptimeStats, 0, len(periods))
+	for _, period := range periods {
+		r.Periods, err = r.calculate(r.Periods, period, options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply clips the report to the requested range and adds the uptime
+// statistics.
+func (r *Report) Apply(options ReportOptions) error {
+	if !options.Since.IsZero() || !options.Until.IsZero() {
+		r.Clip(Interval{Start: r.start(options), End: r.end(options)})
+	}
+
+	err := r.CalculateUptime(options)
+	if err != nil {
+		return err
+	}
+
+	return r.CalculatePeriods(options)
+}
+
+// ReportRenderer is a report that can be rendered in any of the report formats.
+type ReportRenderer interface {
+	EmailMessage
+	Initialize() error
+	Render(format string) (string, error)
+}
+
+// ReportMessage creates an html and text report of the data store.
+type ReportMessage struct {
+	Store   *StoreMaster
+	Options ReportOptions
+	Report  *Report
+	context pongo2.Context
+}
+
+func (r *ReportMessage) Initialize() error {
+	r.Report = NewReport(r.Store, time.Now())
+
+	err := r.Report.Apply(r.Options)
+	if err != nil {
+		return err
 	}
 
 	r.context = pongo2.Context{
-		"url":     r.Store.Url,
-		"current": current,
-		"history": hist,
+		"url":    r.Report.Url,
+		"report": r.Report,
 	}
+	return nil
 }
 
 func (r *ReportMessage) Subject() string {
@@ -124,3 +379,56 @@ func (r *ReportMessage) ToHtml() string {
 func (r *ReportMessage) ToText() string {
 	return RenderTemplate("report-email.txt", &r.context, false)
 }
+
+func (r *ReportMessage) ToMarkdown() string {
+	return RenderTemplate("report.md", &r.context, false)
+}
+
+func (r *ReportMessage) ToJson() string {
+	content, err := json.MarshalIndent(r.Report, "", "  ")
+	PanicOnError(err)
+	return string(content) + "\n"
+}
+
+func (r *ReportMessage) ToCsv() string {
+	b := strings.Builder{}
+	w := csv.NewWriter(&b)
+
+	err := w.Write([]string{"url", "status", "start", "end", "duration", "count", "message"})
+	PanicOnError(err)
+
+	for _, record := range r.Report.Records() {
+		err = w.Write([]string{
+			r.Report.Url,
+			record.Status,
+			record.Start.Format(time.RFC3339),
+			record.End.Format(time.RFC3339),
+			strconv.FormatFloat(record.Duration, 'f', 0, 64),
+			strconv.FormatInt(record.Count, 10),
+			record.Message,
+		})
+		PanicOnError(err)
+	}
+
+	w.Flush()
+	PanicOnError(w.Error())
+
+	return b.String()
+}
+
+// Render returns the report in the requested format.
+func (r *ReportMessage) Render(format string) (string, error) {
+	switch format {
+	case FormatText, "":
+		return r.ToText(), nil
+	case FormatHtml:
+		return r.ToHtml(), nil
+	case FormatJson:
+		return r.ToJson(), nil
+	case FormatCsv:
+		return r.ToCsv(), nil
+	case FormatMarkdown:
+		return r.ToMarkdown(), nil
+	}
+	return "", errors.New(fmt.Sprintf("unknown report format: %s", format))
+}
@@ -1,8 +1,11 @@
 package pkg
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"math"
+	"strings"
 	"testing"
 	"time"
 )
@@ -66,3 +69,185 @@ func TestStoreRecordReport(t *testing.T) {
 		StoreRecordStatusReport(&record),
 	)
 }
+
+func testStoreMaster() *StoreMaster {
+	store := NewStoreMaster("https://markgemmill.com", "test")
+	store.Passes = []StoreRecord{
+		{},
+		{
+			Start:  time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC),
+			Last:   time.Date(2022, 9, 2, 10, 0, 0, 0, time.UTC),
+			Status: PASS,
+			Count:  288,
+		},
+	}
+	store.Failures = []StoreRecord{
+		{
+			Start:   time.Date(2022, 9, 2, 10, 5, 0, 0, time.UTC),
+			Last:    time.Date(2022, 9, 2, 11, 5, 0, 0, time.UTC),
+			Status:  FAIL,
+			Count:   12,
+			Message: "Could not fetch url.; ",
+		},
+	}
+	store.Current = StoreRecord{
+		Start:  time.Date(2022, 9, 2, 11, 10, 0, 0, time.UTC),
+		Last:   time.Date(2022, 9, 3, 11, 10, 0, 0, time.UTC),
+		Status: PASS,
+		Count:  288,
+	}
+	return store
+}
+
+func TestNewReport(t *testing.T) {
+	report := NewReport(testStoreMaster(), time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, 2, len(report.History))
+	assert.Equal(t, PASS, report.History[0].Status)
+	assert.Equal(t, FAIL, report.History[1].Status)
+	assert.Equal(t, 3600.0, report.History[1].Duration)
+	assert.Equal(t, 3, len(report.Records()))
+}
+
+func TestReportMessageRenderJson(t *testing.T) {
+	message := ReportMessage{Store: testStoreMaster()}
+	message.Initialize()
+
+	out, err := message.Render(FormatJson)
+	assert.Nil(t, err)
+
+	report := Report{}
+	assert.Nil(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, "https://markgemmill.com", report.Url)
+	assert.Equal(t, int64(12), report.History[1].Count)
+	assert.Equal(t, "Could not fetch url.; ", report.History[1].Message)
+}
+
+func TestReportMessageRenderCsv(t *testing.T) {
+	message := ReportMessage{Store: testStoreMaster()}
+	message.Initialize()
+
+	out, err := message.Render(FormatCsv)
+	assert.Nil(t, err)
+
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(rows))
+	assert.Equal(t, []string{"url", "status", "start", "end", "duration", "count", "message"}, rows[0])
+	assert.Equal(t, []string{
+		"https://markgemmill.com",
+		FAIL,
+		"2022-09-02T10:05:00Z",
+		"2022-09-02T11:05:00Z",
+		"3600",
+		"12",
+		"Could not fetch url.; ",
+	}, rows[2])
+}
+
+func TestReportMessageRenderMarkdown(t *testing.T) {
+	message := ReportMessage{Store: testStoreMaster()}
+	message.Initialize()
+
+	out, err := message.Render(FormatMarkdown)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "| FAIL | 2022-09-02 10:05:00 | 2022-09-02 11:05:00 | 1 hour | 12 |")
+}
+
+func TestReportMessageRenderMarkdownEscapesCells(t *testing.T) {
+	store := testStoreMaster()
+	store.Failures[0].Message = "contains the rejected text 'a|b'; step 'login':\nline two; "
+	store.Silences = []Silence{{Start: time.Date(2022, 9, 2, 10, 0, 0, 0, time.UTC), End: time.Date(2022, 9, 2, 12, 0, 0, 0, time.UTC), Reason: "db | dns\r\nmove"}}
+	message := ReportMessage{Store: store}
+	message.Initialize()
+
+	out, err := message.Render(FormatMarkdown)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "| 12 | contains the rejected text &#39;a\\|b&#39;; step &#39;login&#39;:<br>line two;  |\n")
+	assert.Contains(t, out, "| db \\| dns<br>move |\n")
+
+	store.Url = "https://some.url.com/search?q=a|b"
+	digest := DigestMessage{Stores: []*StoreMaster{store}}
+	assert.Nil(t, digest.Initialize())
+	out = digest.ToMarkdown()
+	assert.Contains(t, out, "&#39;a\\|b&#39;; step &#39;login&#39;:<br>line two;  |\n")
+	assert.Contains(t, out, "| <https://some.url.com/search?q=a\\|b> |")
+}
+
+func TestReportMessageRenderUnknown(t *testing.T) {
+	message := ReportMessage{Store: testStoreMaster()}
+	message.Initialize()
+
+	_, err := message.Render("xml")
+	assert.NotNil(t, err)
+}
+
+func TestReportMessageUptime(t *testing.T) {
+	message := ReportMessage{
+		Store: testStoreMaster(),
+		Options: ReportOptions{
+			Windows:   []string{"24h", "month"},
+			SlaTarget: 99.9,
+		},
+	}
+	assert.Nil(t, message.Initialize())
+	assert.Equal(t, 2, len(message.Report.Uptime))
+
+	out := message.ToText()
+	assert.Contains(t, out, "24h:")
+	assert.Contains(t, out, "SLA 99.9%")
+
+	out = message.ToHtml()
+	assert.Contains(t, out, "ERROR BUDGET")
+
+	message.Options.Windows = []string{"forever"}
+	assert.NotNil(t, message.Initialize())
+}
+
+func TestReportClip(t *testing.T) {
+	report := NewReport(testStoreMaster(), time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC))
+	report.Clip(Interval{
+		Start: time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC),
+	})
+
+	assert.Equal(t, 2, len(report.History))
+	assert.Equal(t, time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC), report.History[0].Start)
+	assert.Equal(t, 36000.0, report.History[0].Duration)
+	assert.Equal(t, PASS, report.Current.Status)
+	assert.Equal(t, time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC), report.Current.End)
+
+	report = NewReport(testStoreMaster(), time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC))
+	report.Clip(Interval{
+		Start: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2022, 9, 2, 10, 30, 0, 0, time.UTC),
+	})
+
+	assert.Equal(t, 2, len(report.History))
+	assert.Equal(t, time.Date(2022, 9, 2, 10, 30, 0, 0, time.UTC), report.History[1].End)
+	assert.Equal(t, "", report.Current.Status)
+}
+
+func TestReportMessagePeriods(t *testing.T) {
+	message := ReportMessage{
+		Store: testStoreMaster(),
+		Options: ReportOptions{
+			Since:   time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+			Until:   time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC),
+			GroupBy: GroupByDay,
+		},
+	}
+	assert.Nil(t, message.Initialize())
+
+	periods := message.Report.Periods
+	assert.Equal(t, 2, len(periods))
+	assert.Equal(t, "2022-09-01", periods[0].Window)
+	assert.Equal(t, 100.0, periods[0].Uptime)
+	assert.Equal(t, 0, periods[0].Incidents)
+	assert.Equal(t, 3900.0, periods[1].Downtime)
+	assert.Equal(t, 1, periods[1].Incidents)
+
+	out := message.ToText()
+	assert.Contains(t, out, "Availability By Day")
+	assert.Contains(t, out, "2022-09-02: 95.486% uptime, 1 hour and 5 minutes downtime, 1 incident.")
+}
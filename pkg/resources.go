@@ -4,6 +4,7 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"github.com/spf13/afero"
 	iofs "io/fs"
 	"path"
 )
@@ -13,6 +14,16 @@ var (
 	emfs embed.FS
 )
 
+// templateDir is an optional directory of user templates that override
+// the embedded templates of the same name.
+var templateDir string
+
+// SetTemplateDirectory sets the directory searched for user templates
+// before the embedded templates.
+func SetTemplateDirectory(dir string) {
+	templateDir = dir
+}
+
 // Resources represents a resource directory.
 type Resources struct {
 	directory string
@@ -26,6 +37,21 @@ func (r *Resources) Initialize() {
 
 // ReadText opens and reads the named resource from the directory as text.
 func (r *Resources) ReadText(name string) (string, error) {
+	if templateDir != "" {
+		override := path.Join(templateDir, name)
+		exists, err := afero.Exists(fs, override)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			text, err := afero.ReadFile(fs, override)
+			if err != nil {
+				return "", err
+			}
+			return string(text), nil
+		}
+	}
+
 	for _, template := range r.templates {
 		if template.Name() == name && !template.Type().IsDir() {
 			text, err := iofs.ReadFile(emfs, path.Join(r.directory, template.Name()))
@@ -0,0 +1,256 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// The backoffs of a RetryPolicy.
+const (
+	BackoffFixed       = "fixed"
+	BackoffLinear      = "linear"
+	BackoffExponential = "exponential"
+	BackoffPower       = "power"
+)
+
+/*
+The failure classes of a failed check, see Result.FailureClasses. A url that
+cannot be fetched is a connection failure. A failed status assertion is a
+5xx, 4xx or status failure by the status code received, a failed redirect
+or final url assertion is a redirect failure, and any other failed
+assertion is a content failure.
+*/
+const (
+	FailureConnection = "connection"
+	Failure5xx        = "5xx"
+	Failure4xx        = "4xx"
+	FailureStatus     = "status"
+	FailureRedirect   = "redirect"
+	FailureContent    = "content"
+)
+
+var failureClasses = []string{FailureConnection, Failure5xx, Failure4xx, FailureStatus, FailureRedirect, FailureContent}
+
+// DefaultRetryDelay is the delay of a RetryPolicy without one.
+const DefaultRetryDelay = time.Second
+
+// assertionClass returns the failure class of the assertion, status,
+// redirect or content.
+func assertionClass(assertion Assertion) string {
+	switch a := assertion.(type) {
+	case *WarningAssertion:
+		return assertionClass(a.Assertion)
+	case *StatusCodeAssertion:
+		return FailureStatus
+	case *FinalUrlAssertion, *RedirectCountAssertion, *RedirectTargetAssertion:
+		return FailureRedirect
+	default:
+		return FailureContent
+	}
+}
+
+// FailureClasses returns the classes of the failures of the result.
+func (r *Result) FailureClasses() []string {
+	if r.Pass {
+		return []string{}
+	}
+	if r.Response.Fail {
+		return []string{FailureConnection}
+	}
+
+	classes := make([]string, 0)
+	add := func(class string) {
+		for _, c := range classes {
+			if c == class {
+				return
+			}
+		}
+		classes = append(classes, class)
+	}
+	for _, assertion := range r.Assertions {
+		if assertion.Pass || assertion.Severity == SeverityWarning {
+			continue
+		}
+		switch assertion.Class {
+		case FailureStatus:
+			if r.Response.StatusCode >= 500 && r.Response.StatusCode < 600 {
+				add(Failure5xx)
+			} else if r.Response.StatusCode >= 400 && r.Response.StatusCode < 500 {
+				add(Failure4xx)
+			} else {
+				add(FailureStatus)
+			}
+		case FailureRedirect:
+			add(FailureRedirect)
+		default:
+			add(FailureContent)
+		}
+	}
+	return classes
+}
+
+/*
+RetryPolicy decides whether and when a failed check is retried. Example:
+
+	policy := pkg.RetryPolicy{
+		Retries:  5,
+		Backoff:  pkg.BackoffExponential,
+		Delay:    pkg.Duration{Duration: time.Second},
+		MaxDelay: pkg.Duration{Duration: 30 * time.Second},
+		Jitter:   true,
+		On:       []string{pkg.FailureConnection, pkg.Failure5xx},
+	}
+
+The zero policy never retries.
+*/
+type RetryPolicy struct {
+	// Retries is the most retries after the first check.
+	Retries int `json:"retries"`
+	// Backoff is fixed, linear, exponential or power, BackoffLinear if empty.
+	Backoff string `json:"backoff"`
+	// Exponent raises the retry to a power for the power backoff, 1 if empty.
+	Exponent int `json:"exponent"`
+	// Delay is the pause before the first retry, DefaultRetryDelay if empty.
+	Delay Duration `json:"delay"`
+	// MaxDelay caps the pause between retries, uncapped if empty.
+	MaxDelay Duration `json:"max-delay"`
+	// Jitter pauses for a random time up to the backoff, known as full jitter.
+	Jitter bool `json:"jitter"`
+	// On are the failure classes retried, every class if empty.
+	On []string `json:"on"`
+	// Random returns a number from 0 up to 1 for the jitter, rand.Float64 if nil.
+	Random func() float64 `json:"-"`
+}
+
+// Validate returns an error if the backoff or a failure class is unknown.
+func (p *RetryPolicy) Validate() error {
+	if p.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	switch p.Backoff {
+	case "", BackoffFixed, BackoffLinear, BackoffExponential, BackoffPower:
+	default:
+		return errors.New(fmt.Sprintf("'%s' is an unknown backoff, expecting fixed, linear, exponential or power.", p.Backoff))
+	}
+	if p.Exponent < 0 {
+		return errors.New("the backoff exponent must not be negative")
+	}
+	if p.Delay.Duration < 0 || p.MaxDelay.Duration < 0 {
+		return errors.New("retry delays must not be negative")
+	}
+	for _, class := range p.On {
+		known := false
+		for _, c := range failureClasses {
+			known = known || c == class
+		}
+		if !known {
+			return errors.New(fmt.Sprintf("'%s' is an unknown failure class, expecting one of %s.", class, strings.Join(failureClasses, ", ")))
+		}
+	}
+	return nil
+}
+
+// Retryable returns true if the result failed with a class the policy retries.
+func (p *RetryPolicy) Retryable(result Result) bool {
+	if result.Pass {
+		return false
+	}
+	if len(p.On) == 0 {
+		return true
+	}
+	for _, class := range result.FailureClasses() {
+		for _, on := range p.On {
+			if class == on {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+Pause returns the time to wait before the given retry, counting from 1.
+The fixed backoff always waits the delay, linear waits the delay times the
+retry, exponential doubles the delay with each retry and power waits the
+delay times the retry raised to the exponent, as CalculatePauseInSeconds
+does in seconds. The pause is
+capped by the max delay, then with jitter is a random time up to it.
+*/
+func (p *RetryPolicy) Pause(retry int) time.Duration {
+	delay := p.Delay.Duration
+	if delay == 0 {
+		delay = DefaultRetryDelay
+	}
+
+	pause := float64(delay)
+	switch p.Backoff {
+	case BackoffFixed:
+	case BackoffExponential:
+		pause *= math.Pow(2, float64(retry-1))
+	case BackoffPower:
+		exponent := p.Exponent
+		if exponent == 0 {
+			exponent = 1
+		}
+		pause *= CalculatePauseInSeconds(retry, exponent).Seconds()
+	default:
+		pause *= float64(retry)
+	}
+	if p.MaxDelay.Duration > 0 && pause > float64(p.MaxDelay.Duration) {
+		pause = float64(p.MaxDelay.Duration)
+	}
+	if pause >= math.MaxInt64 {
+		pause = math.Nextafter(math.MaxInt64, 0)
+	}
+
+	if p.Jitter {
+		random := p.Random
+		if random == nil {
+			random = rand.Float64
+		}
+		pause *= random()
+	}
+	return time.Duration(pause)
+}
+
+/*
+RetryCheck runs the check as RunCheck, then retries a failure as the policy
+allows until a check passes. Every attempt is saved, emitted and logged,
+the retries with their attempt number. It returns the last result and the
+updated store, or an error if the context is cancelled while waiting.
+*/
+func RetryCheck(ctx context.Context, spec CheckSpec, storeName string, tags map[string]string, policy RetryPolicy, console Logger) (Result, *Store, error) {
+	result, store, err := RunCheck(ctx, spec, storeName, tags, console)
+	if err != nil {
+		return result, store, err
+	}
+
+	for retry := 1; retry <= policy.Retries && !result.Pass; retry++ {
+		classes := result.FailureClasses()
+		if !policy.Retryable(result) {
+			console.Log(2, "check.no_retry", Fields{"failures": strings.Join(classes, ",")}, "Not retrying %s failure.\n", strings.Join(classes, ", "))
+			break
+		}
+
+		pause := policy.Pause(retry)
+		console.Log(2, "check.retry", Fields{"retry": retry, "delay_ms": pause.Milliseconds(), "failures": strings.Join(classes, ",")}, Green("Retry #%d in %s...\n"), retry, pause)
+		select {
+		case <-ctx.Done():
+			return result, store, ctx.Err()
+		case <-time.After(pause):
+		}
+
+		EmitRetry(spec.Emitters, store.Name, tags, console)
+		result, store, err = RunCheck(ctx, spec, storeName, tags, console.With(Fields{"attempt": retry + 1}))
+		if err != nil {
+			return result, store, err
+		}
+	}
+
+	return result, store, nil
+}
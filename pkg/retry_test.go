@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFailureClasses(t *testing.T) {
+	assert.Equal(t, []string{}, (&Result{Pass: true}).FailureClasses())
+	assert.Equal(t, []string{FailureConnection}, (&Result{Response: UrlResult{Fail: true}}).FailureClasses())
+
+	result := Result{
+		Response: UrlResult{StatusCode: 503},
+		Assertions: []AssertionResult{
+			{Name: "Status Code Assertion", Class: FailureStatus, Severity: SeverityCritical},
+			{Name: "Content Assertion", Class: FailureContent, Severity: SeverityCritical},
+			{Name: "Rejected Content Assertion", Class: FailureContent, Severity: SeverityCritical},
+			{Name: "Redirect Count Assertion", Class: FailureRedirect, Severity: SeverityCritical, Pass: true},
+			{Name: "Content Assertion", Class: FailureContent, Severity: SeverityWarning},
+		},
+	}
+	assert.Equal(t, []string{Failure5xx, FailureContent}, result.FailureClasses())
+
+	result.Response.StatusCode = 404
+	assert.Equal(t, Failure4xx, result.FailureClasses()[0])
+	result.Response.StatusCode = 302
+	assert.Equal(t, FailureStatus, result.FailureClasses()[0])
+
+	// the class is taken from the type of the assertion, not its name
+	var warning Assertion = NewWarningAssertion(NewRedirectCountAssertion(1))
+	assert.Equal(t, FailureRedirect, assertionClass(warning))
+	assert.Equal(t, FailureStatus, assertionClass(NewStatusCodeAssertion(200)))
+	assert.Equal(t, FailureContent, assertionClass(NewContentAssertion("active")))
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	assert.Nil(t, (&RetryPolicy{}).Validate())
+	assert.Nil(t, (&RetryPolicy{Retries: 3, Backoff: BackoffExponential, On: []string{FailureConnection, Failure5xx}}).Validate())
+	assert.EqualError(t, (&RetryPolicy{Backoff: "random"}).Validate(), "'random' is an unknown backoff, expecting fixed, linear, exponential or power.")
+	assert.EqualError(t, (&RetryPolicy{On: []string{"timeout"}}).Validate(), "'timeout' is an unknown failure class, expecting one of connection, 5xx, 4xx, status, redirect, content.")
+	assert.NotNil(t, (&RetryPolicy{Retries: -1}).Validate())
+	assert.NotNil(t, (&RetryPolicy{Delay: Duration{-time.Second}}).Validate())
+}
+
+func TestRetryPolicyPause(t *testing.T) {
+	fixed := RetryPolicy{Backoff: BackoffFixed, Delay: Duration{2 * time.Second}}
+	linear := RetryPolicy{Backoff: BackoffLinear}
+	exponential := RetryPolicy{Backoff: BackoffExponential, Delay: Duration{time.Second}, MaxDelay: Duration{10 * time.Second}}
+
+	data := []struct {
+		retry       int
+		fixed       time.Duration
+		linear      time.Duration
+		exponential time.Duration
+	}{
+		{1, 2 * time.Second, 1 * time.Second, 1 * time.Second},
+		{2, 2 * time.Second, 2 * time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+		{4, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		{5, 2 * time.Second, 5 * time.Second, 10 * time.Second},
+		{100, 2 * time.Second, 100 * time.Second, 10 * time.Second},
+	}
+	for _, d := range data {
+		assert.Equal(t, d.fixed, fixed.Pause(d.retry))
+		assert.Equal(t, d.linear, linear.Pause(d.retry))
+		assert.Equal(t, d.exponential, exponential.Pause(d.retry))
+	}
+
+	// the power backoff raises the retry to the exponent, as --retry-increment
+	power := RetryPolicy{Backoff: BackoffPower, Exponent: 3}
+	assert.Equal(t, 1*time.Second, power.Pause(1))
+	assert.Equal(t, 8*time.Second, power.Pause(2))
+	assert.Equal(t, 27*time.Second, power.Pause(3))
+	assert.Equal(t, 3*time.Second, (&RetryPolicy{Backoff: BackoffPower}).Pause(3))
+
+	// an uncapped exponential backoff does not overflow
+	assert.True(t, (&RetryPolicy{Backoff: BackoffExponential}).Pause(200) > 0)
+
+	// full jitter is a random pause up to the capped backoff
+	exponential.Jitter = true
+	exponential.Random = func() float64 { return 0.25 }
+	assert.Equal(t, 1*time.Second, exponential.Pause(3))
+	assert.Equal(t, 2500*time.Millisecond, exponential.Pause(7))
+	exponential.Random = nil
+	for retry := 1; retry < 10; retry++ {
+		pause := exponential.Pause(retry)
+		assert.True(t, pause >= 0 && pause <= 10*time.Second)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	connection := Result{Response: UrlResult{Fail: true}}
+	content := Result{Response: UrlResult{StatusCode: 200}, Assertions: []AssertionResult{{Name: "Content Assertion", Severity: SeverityCritical}}}
+
+	all := RetryPolicy{}
+	assert.True(t, all.Retryable(connection))
+	assert.True(t, all.Retryable(content))
+	assert.False(t, all.Retryable(Result{Pass: true}))
+
+	some := RetryPolicy{On: []string{FailureConnection, Failure5xx}}
+	assert.True(t, some.Retryable(connection))
+	assert.False(t, some.Retryable(content))
+}
+
+// flakyServer fails the first failures requests with the status, then passes.
+func flakyServer(t *testing.T, failures int, status int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if requests <= failures {
+			w.WriteHeader(status)
+		}
+		_, _ = w.Write([]byte("active"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryCheck(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server, requests := flakyServer(t, 2, http.StatusServiceUnavailable)
+	policy := RetryPolicy{Retries: 5, Backoff: BackoffFixed, Delay: Duration{time.Millisecond}, On: []string{Failure5xx}}
+
+	recorder := NewRecorder(2)
+	result, store, err := RetryCheck(context.Background(), CheckSpec{Url: server.URL}, "flaky", nil, policy, recorder)
+	assert.Nil(t, err)
+	assert.True(t, result.Pass)
+	// the retries stop at the first check that passes
+	assert.Equal(t, 3, *requests)
+	assert.Equal(t, PASS, store.Data.Current.Status)
+
+	retries := make([]LogEvent, 0)
+	for _, event := range recorder.Events() {
+		if event.Event == "check.retry" {
+			retries = append(retries, event)
+		}
+		if event.Event == "check.pass" {
+			assert.Equal(t, 3, event.Fields["attempt"])
+		}
+	}
+	assert.Equal(t, 2, len(retries))
+	assert.Equal(t, 2, retries[1].Fields["retry"])
+	assert.Equal(t, Failure5xx, retries[1].Fields["failures"])
+}
+
+func TestRetryCheckFailureClass(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server, requests := flakyServer(t, 2, http.StatusNotFound)
+	policy := RetryPolicy{Retries: 5, Delay: Duration{time.Millisecond}, On: []string{FailureConnection, Failure5xx}}
+
+	recorder := NewRecorder(2)
+	result, _, err := RetryCheck(context.Background(), CheckSpec{Url: server.URL}, "flaky", nil, policy, recorder)
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	assert.Equal(t, 1, *requests)
+	assert.Equal(t, "check.no_retry", recorder.Events()[len(recorder.Events())-1].Event)
+
+	// every retry fails
+	*requests = -10
+	result, store, err := RetryCheck(context.Background(), CheckSpec{Url: server.URL}, "flaky", nil, RetryPolicy{Retries: 2, Delay: Duration{time.Millisecond}}, recorder)
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	assert.Equal(t, -7, *requests)
+	assert.Equal(t, int64(4), store.Data.Current.Count)
+}
+
+func TestRetryCheckCancelled(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server, requests := flakyServer(t, 2, http.StatusServiceUnavailable)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, _, err := RetryCheck(ctx, CheckSpec{Url: server.URL}, "flaky", nil, RetryPolicy{Retries: 1, Delay: Duration{time.Minute}}, NewRecorder(0))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, *requests)
+}
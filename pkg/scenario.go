@@ -0,0 +1,492 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Extractor saves a value of a step's response to a variable, which later
+steps use as ${name}. The value is taken from one of: the first group of
+a regex matched against the content (or the whole match if it has no
+group), a json path such as $.data.items[0].id, or a response header.
+*/
+type Extractor struct {
+	Name     string `json:"name"`
+	Regex    string `json:"regex"`
+	JsonPath string `json:"json-path"`
+	Header   string `json:"header"`
+}
+
+var variableName = regexp.MustCompile(`^\w+$`)
+
+// variablePattern matches a ${name} variable of a step.
+var variablePattern = regexp.MustCompile(`\$\{(\w+)\}`)
+
+func (e *Extractor) Validate() error {
+	if !variableName.MatchString(e.Name) {
+		return errors.New(fmt.Sprintf("'%s' is not a valid variable name.", e.Name))
+	}
+	sources := 0
+	for _, source := range []string{e.Regex, e.JsonPath, e.Header} {
+		if source != "" {
+			sources += 1
+		}
+	}
+	if sources != 1 {
+		return errors.New(fmt.Sprintf("the variable %s must be extracted by one of regex, json-path or header.", e.Name))
+	}
+	if e.Regex != "" {
+		_, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return errors.New(fmt.Sprintf("the variable %s has an invalid regex: %s", e.Name, err))
+		}
+	}
+	if e.JsonPath != "" {
+		_, err := parseJsonPath(e.JsonPath)
+		return err
+	}
+	return nil
+}
+
+// Extract returns the value of the variable in the response.
+func (e *Extractor) Extract(response *UrlResult) (string, error) {
+	switch {
+	case e.Header != "":
+		value := response.Header.Get(e.Header)
+		if value == "" {
+			return "", errors.New(fmt.Sprintf("the response has no %s header", e.Header))
+		}
+		return value, nil
+	case e.JsonPath != "":
+		return jsonPathValue(response.Content, e.JsonPath)
+	default:
+		match := regexp.MustCompile(e.Regex).FindStringSubmatch(response.Content)
+		if match == nil {
+			return "", errors.New(fmt.Sprintf("'%s' does not match the content", e.Regex))
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	}
+}
+
+// parseJsonPath splits a json path into its keys and array indexes.
+// Example: $.data.items[0].id is data, items, 0 and id.
+func parseJsonPath(path string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	trimmed = strings.ReplaceAll(strings.ReplaceAll(trimmed, "[", "."), "]", "")
+	if trimmed == "" {
+		return nil, errors.New(fmt.Sprintf("'%s' is not a valid json path.", path))
+	}
+	keys := strings.Split(trimmed, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, errors.New(fmt.Sprintf("'%s' is not a valid json path.", path))
+		}
+	}
+	return keys, nil
+}
+
+// jsonPathValue returns the value at the json path of the content. Objects
+// and arrays are returned as json.
+func jsonPathValue(content, path string) (string, error) {
+	keys, err := parseJsonPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	err = json.Unmarshal([]byte(content), &value)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("the content is not json: %s", err))
+	}
+
+	for _, key := range keys {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return "", errors.New(fmt.Sprintf("'%s' is not in the content", path))
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", errors.New(fmt.Sprintf("'%s' is not in the content", path))
+			}
+			value = node[index]
+		default:
+			return "", errors.New(fmt.Sprintf("'%s' is not in the content", path))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "null", nil
+	default:
+		text, _ := json.Marshal(v)
+		return string(text), nil
+	}
+}
+
+/*
+ScenarioStep is a single request of a scenario. The url, headers, body and
+form may use the ${name} variables extracted by earlier steps. A form is
+sent url encoded, as a POST unless the method is given.
+*/
+type ScenarioStep struct {
+	Name            string            `json:"name"`
+	Method          string            `json:"method"`
+	Url             string            `json:"url"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	Form            map[string]string `json:"form"`
+	ExpectedStatus  StatusExpression  `json:"expect-status"`
+	ExpectedContent string            `json:"expect-content"`
+	RejectContent   []string          `json:"reject-content"`
+	FollowRedirects RedirectLimit     `json:"follow-redirects"`
+	Extract         []Extractor       `json:"extract"`
+	// Assertions are run after the expected status and content assertions.
+	Assertions []Assertion `json:"-"`
+}
+
+// method returns the http method of the step.
+func (s *ScenarioStep) method() string {
+	if s.Method != "" {
+		return strings.ToUpper(s.Method)
+	}
+	if len(s.Form) > 0 {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// spec returns the spec whose assertions are run against the response.
+func (s *ScenarioStep) spec() CheckSpec {
+	rules := make([]ContentRule, 0, len(s.RejectContent))
+	for _, pattern := range s.RejectContent {
+		rules = append(rules, ContentRule{Pattern: pattern, Negate: true})
+	}
+	return CheckSpec{
+		Url:             s.Url,
+		ExpectedStatus:  s.ExpectedStatus,
+		ExpectedContent: s.ExpectedContent,
+		ContentRules:    rules,
+		FollowRedirects: s.FollowRedirects,
+		Assertions:      s.Assertions,
+	}
+}
+
+// variables returns the names of the variables used by the step.
+func (s *ScenarioStep) variables() []string {
+	texts := []string{s.Url, s.Body}
+	for name, value := range s.Headers {
+		texts = append(texts, name, value)
+	}
+	for name, value := range s.Form {
+		texts = append(texts, name, value)
+	}
+
+	names := make([]string, 0)
+	for _, text := range texts {
+		for _, match := range variablePattern.FindAllStringSubmatch(text, -1) {
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+request returns the request of the step with its variables replaced by
+their values. A value in the path of the url is path escaped and one in
+its query is query escaped, while the body, form and headers are given the
+value as it is. It returns an error if a variable has no value.
+*/
+func (s *ScenarioStep) request(variables map[string]string) (UrlRequest, error) {
+	var undefined error
+	interpolate := func(text string, escape func(string) string) string {
+		return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+			name := variablePattern.FindStringSubmatch(match)[1]
+			value, ok := variables[name]
+			if !ok && undefined == nil {
+				undefined = errors.New(fmt.Sprintf("uses the variable %s, which is not defined", name))
+			}
+			if escape == nil {
+				return value
+			}
+			return escape(value)
+		})
+	}
+
+	// the scheme and host are kept as given, so a variable can hold a base or
+	// a whole url, while a value in the path keeps its slashes
+	path, query := s.Url, ""
+	if i := strings.Index(s.Url, "?"); i >= 0 {
+		path, query = s.Url[:i], s.Url[i:]
+	}
+	prefix := ""
+	start := strings.Index(path, "://")
+	if start >= 0 {
+		start += len("://")
+	} else {
+		start = 0
+	}
+	if i := strings.Index(path[start:], "/"); i >= 0 {
+		prefix, path = path[:start+i], path[start+i:]
+	} else {
+		prefix, path = path, ""
+	}
+
+	request := UrlRequest{
+		Method: s.method(),
+		Url:    interpolate(prefix, nil) + interpolate(path, escapePath) + interpolate(query, url.QueryEscape),
+		Header: http.Header{},
+		Body:   interpolate(s.Body, nil),
+	}
+	for name, value := range s.Headers {
+		request.Header.Set(interpolate(name, nil), interpolate(value, nil))
+	}
+	if len(s.Form) > 0 {
+		form := url.Values{}
+		for name, value := range s.Form {
+			form.Set(interpolate(name, nil), interpolate(value, nil))
+		}
+		request.Body = form.Encode()
+		if request.Header.Get("Content-Type") == "" {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	return request, undefined
+}
+
+// escapePath escapes each segment of a path, keeping its slashes.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// StepResult is the outcome of a single step of a scenario.
+type StepResult struct {
+	Name       string            `json:"name"`
+	Method     string            `json:"method"`
+	Url        string            `json:"url"`
+	StatusCode int               `json:"status"`
+	Duration   float64           `json:"duration"`
+	Pass       bool              `json:"pass"`
+	Error      string            `json:"error,omitempty"`
+	Assertions []AssertionResult `json:"assertions"`
+}
+
+/*
+Scenario is a check of a journey through a site, such as logging in and
+then calling an api with the session cookie. Its steps are requested in
+order with a shared cookie jar, and the scenario stops at the first step
+that fails. Example:
+
+	{"url": "https://some.url.com/login", "store-name": "login", "steps": [
+	  {"name": "login", "url": "https://some.url.com/login", "form": {"user": "pingu", "password": "secret"},
+	   "extract": [{"name": "token", "json-path": "$.token"}]},
+	  {"name": "dashboard", "url": "https://some.url.com/dashboard", "expect-content": "Welcome"},
+	  {"name": "api", "url": "https://some.url.com/api/orders", "headers": {"Authorization": "Bearer ${token}"}}
+	]}
+*/
+type Scenario struct {
+	Url    string
+	Steps  []ScenarioStep
+	Client *http.Client
+}
+
+// scenario returns the scenario of a spec with steps.
+func (s *CheckSpec) scenario() *Scenario {
+	return &Scenario{Url: s.Url, Steps: s.Steps, Client: s.Client}
+}
+
+// stepName returns the name of the step, or its number if it has none.
+func (s *Scenario) stepName(i int) string {
+	if s.Steps[i].Name != "" {
+		return s.Steps[i].Name
+	}
+	return fmt.Sprintf("step %d", i+1)
+}
+
+/*
+Validate returns an error if a step cannot be checked, or uses a variable
+that is not extracted by an earlier step.
+*/
+func (s *Scenario) Validate() error {
+	if s.Url == "" {
+		return errors.New("check spec has no url")
+	}
+
+	extracted := make(map[string]bool)
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		name := s.stepName(i)
+		if step.Url == "" {
+			return errors.New(fmt.Sprintf("step '%s' has no url", name))
+		}
+		if step.Body != "" && len(step.Form) > 0 {
+			return errors.New(fmt.Sprintf("step '%s' cannot have both a body and a form", name))
+		}
+		spec := step.spec()
+		err := spec.Validate()
+		if err != nil {
+			return errors.New(fmt.Sprintf("step '%s' %s", name, err))
+		}
+		for _, variable := range step.variables() {
+			if !extracted[variable] {
+				return errors.New(fmt.Sprintf("step '%s' uses the variable %s before it is extracted", name, variable))
+			}
+		}
+		for _, extractor := range step.Extract {
+			err = extractor.Validate()
+			if err != nil {
+				return errors.New(fmt.Sprintf("step '%s' %s", name, err))
+			}
+			extracted[extractor.Name] = true
+		}
+	}
+	return nil
+}
+
+/*
+Check runs the steps of the scenario in order, stopping at the first that
+fails. The result is that of the whole scenario: its errors are prefixed
+with the name of the failed step, its response is that of the last step
+run and its total time is the time of every step.
+*/
+func (s *Scenario) Check(ctx context.Context) (Result, error) {
+	result := Result{
+		Url:        s.Url,
+		Checked:    time.Now(),
+		Assertions: make([]AssertionResult, 0),
+		Errors:     make([]string, 0),
+		Warnings:   make([]string, 0),
+		Steps:      make([]StepResult, 0, len(s.Steps)),
+	}
+
+	err := s.Validate()
+	if err != nil {
+		return result, err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return result, err
+	}
+	client := *http.DefaultClient
+	if s.Client != nil {
+		client = *s.Client
+	}
+	client.Jar = jar
+
+	variables := make(map[string]string)
+	total := time.Duration(0)
+	for i := range s.Steps {
+		name := s.stepName(i)
+		step, err := s.checkStep(ctx, &client, i, variables)
+		if err != nil {
+			return result, err
+		}
+		total += step.Response.Timing.Total
+
+		result.Response = step.Response
+		result.Assertions = append(result.Assertions, step.Assertions...)
+		for _, msg := range step.Warnings {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("step '%s': %s", name, msg))
+		}
+		result.Steps = append(result.Steps, StepResult{
+			Name:       name,
+			Method:     s.Steps[i].method(),
+			Url:        step.Url,
+			StatusCode: step.Response.StatusCode,
+			Duration:   step.Response.Timing.Total.Seconds(),
+			Pass:       step.Pass,
+			Error:      step.Response.Error,
+			Assertions: step.Assertions,
+		})
+
+		if !step.Pass {
+			for _, msg := range step.Errors {
+				result.Errors = append(result.Errors, fmt.Sprintf("step '%s': %s", name, msg))
+			}
+			result.FailedStep = name
+			break
+		}
+	}
+
+	result.Response.Timing.Total = total
+	result.Pass = len(result.Errors) == 0
+	return result, nil
+}
+
+// checkStep requests the step and runs its assertions, then extracts its
+// variables from the response.
+func (s *Scenario) checkStep(ctx context.Context, client *http.Client, i int, variables map[string]string) (Result, error) {
+	step := &s.Steps[i]
+	spec := step.spec()
+	maxRedirects, err := spec.FollowRedirects.Max()
+	if err != nil {
+		return Result{}, err
+	}
+	assertions, err := spec.assertions()
+	if err != nil {
+		return Result{}, err
+	}
+
+	request, err := step.request(variables)
+	if err != nil {
+		return Result{}, errors.New(fmt.Sprintf("step '%s' %s", s.stepName(i), err))
+	}
+	result := Result{
+		Url:        request.Url,
+		Checked:    time.Now(),
+		Assertions: make([]AssertionResult, 0),
+		Errors:     make([]string, 0),
+		Warnings:   make([]string, 0),
+	}
+	result.Response = UrlRequestContext(ctx, client, request, maxRedirects)
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if result.Response.Fail {
+		result.Errors = append(result.Errors, "Could not fetch url.")
+		return result, nil
+	}
+
+	runAssertions(&result, assertions)
+	if !result.Pass {
+		return result, nil
+	}
+
+	for _, extractor := range step.Extract {
+		value, err := extractor.Extract(&result.Response)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("could not extract %s: %s", extractor.Name, err))
+			result.Pass = false
+			return result, nil
+		}
+		variables[extractor.Name] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"context"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// journeyServer logs in with a form, setting a session cookie and
+// returning a token, then serves a dashboard to the session and an api to
+// the token.
+func journeyServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		w.Header().Set("X-Account", "42")
+		_, _ = w.Write([]byte(`{"data": {"token": "t0k3n", "accounts": [{"id": 42}]}}`))
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "s1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`<p>Welcome back, order <b id="order">A-17</b></p>`))
+	})
+	mux.HandleFunc("/api/accounts/42/orders/A-17", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "shipped"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func journeySteps(url, password string) []ScenarioStep {
+	return []ScenarioStep{
+		{
+			Name: "login",
+			Url:  url + "/login",
+			Form: map[string]string{"user": "pingu", "password": password},
+			Extract: []Extractor{
+				{Name: "token", JsonPath: "$.data.token"},
+				{Name: "account", Header: "X-Account"},
+			},
+		},
+		{
+			Name:            "dashboard",
+			Url:             url + "/dashboard",
+			ExpectedContent: "Welcome",
+			Extract:         []Extractor{{Name: "order", Regex: `id="order">([^<]+)<`}},
+		},
+		{
+			Name:            "api",
+			Url:             url + "/api/accounts/${account}/orders/${order}",
+			Headers:         map[string]string{"Authorization": "Bearer ${token}"},
+			ExpectedContent: "shipped",
+		},
+	}
+}
+
+func TestScenarioCheck(t *testing.T) {
+	server := journeyServer(t)
+
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL, Steps: journeySteps(server.URL, "secret")})
+	assert.Nil(t, err)
+	assert.True(t, result.Pass)
+	assert.Equal(t, "", result.FailedStep)
+	assert.Equal(t, 3, len(result.Steps))
+	assert.Equal(t, http.MethodPost, result.Steps[0].Method)
+	assert.Equal(t, server.URL+"/api/accounts/42/orders/A-17", result.Steps[2].Url)
+	assert.Equal(t, 200, result.Response.StatusCode)
+}
+
+func TestScenarioCheckFailedStep(t *testing.T) {
+	server := journeyServer(t)
+
+	result, err := Check(context.Background(), CheckSpec{Url: server.URL, Steps: journeySteps(server.URL, "wrong")})
+	assert.Nil(t, err)
+	assert.False(t, result.Pass)
+	// the scenario stops at the failed step
+	assert.Equal(t, 1, len(result.Steps))
+	assert.Equal(t, "login", result.FailedStep)
+	assert.Equal(t, []string{"step 'login': expecting status of 200, but received 401"}, result.Errors)
+	assert.Equal(t, []string{Failure4xx}, result.FailureClasses())
+
+	// a value that cannot be extracted fails the step
+	steps := journeySteps(server.URL, "secret")
+	steps[1].Extract[0].Regex = `id="invoice">([^<]+)<`
+	result, err = Check(context.Background(), CheckSpec{Url: server.URL, Steps: steps})
+	assert.Nil(t, err)
+	assert.Equal(t, "dashboard", result.FailedStep)
+	assert.Contains(t, result.Errors[0], "step 'dashboard': could not extract order:")
+
+	// steps without a name are numbered
+	steps = journeySteps(server.URL, "secret")
+	steps[2].Name = ""
+	steps[2].ExpectedContent = "delivered"
+	result, _ = Check(context.Background(), CheckSpec{Url: server.URL, Steps: steps})
+	assert.Equal(t, "step 3", result.FailedStep)
+}
+
+func TestScenarioValidate(t *testing.T) {
+	steps := journeySteps("https://some.url.com", "secret")
+	assert.Nil(t, (&Scenario{Url: "https://some.url.com", Steps: steps}).Validate())
+
+	steps[1].Extract = nil
+	assert.EqualError(t, (&Scenario{Url: "https://some.url.com", Steps: steps}).Validate(), "step 'api' uses the variable order before it is extracted")
+
+	steps = journeySteps("https://some.url.com", "secret")
+	steps[0].Extract[1] = Extractor{Name: "account", Header: "X-Account", Regex: "[0-9]+"}
+	assert.EqualError(t, (&Scenario{Url: "https://some.url.com", Steps: steps}).Validate(), "step 'login' the variable account must be extracted by one of regex, json-path or header.")
+
+	steps = journeySteps("https://some.url.com", "secret")
+	steps[0].Body = "user=pingu"
+	assert.EqualError(t, (&Scenario{Url: "https://some.url.com", Steps: steps}).Validate(), "step 'login' cannot have both a body and a form")
+
+	steps = journeySteps("https://some.url.com", "secret")
+	steps[1].ExpectedStatus = "2xy"
+	spec := CheckSpec{Url: "https://some.url.com", Steps: steps}
+	assert.NotNil(t, spec.Validate())
+}
+
+func TestScenarioStepRequest(t *testing.T) {
+	step := ScenarioStep{
+		Url:     "https://some.url.com/orders/${order}?q=${query}&page=2",
+		Headers: map[string]string{"Authorization": "Bearer ${token}"},
+		Body:    `{"query": "${query}"}`,
+	}
+	variables := map[string]string{"order": "A 17/b", "query": "a&b=c d", "token": "t0k3n"}
+
+	// a value is escaped where it lands in the url
+	request, err := step.request(variables)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://some.url.com/orders/A%2017/b?q=a%26b%3Dc+d&page=2", request.Url)
+	assert.Equal(t, "Bearer t0k3n", request.Header.Get("Authorization"))
+	assert.Equal(t, `{"query": "a&b=c d"}`, request.Body)
+
+	// a variable holding a whole url or the host is not escaped
+	request, err = (&ScenarioStep{Url: "${next}"}).request(map[string]string{"next": "https://some.url.com/orders?page=2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://some.url.com/orders?page=2", request.Url)
+	request, err = (&ScenarioStep{Url: "${base}/orders/${order}"}).request(map[string]string{"base": "https://some.url.com:8080", "order": "A 17"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://some.url.com:8080/orders/A%2017", request.Url)
+
+	// a path with slashes keeps them
+	request, err = (&ScenarioStep{Url: "https://some.url.com/api/${path}"}).request(map[string]string{"path": "v2/items"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://some.url.com/api/v2/items", request.Url)
+
+	delete(variables, "token")
+	_, err = step.request(variables)
+	assert.EqualError(t, err, "uses the variable token, which is not defined")
+
+	// a scenario that is not validated still fails on an undefined variable
+	scenario := Scenario{Url: "https://some.url.com", Steps: []ScenarioStep{step}}
+	_, err = scenario.checkStep(context.Background(), http.DefaultClient, 0, map[string]string{})
+	assert.EqualError(t, err, "step 'step 1' uses the variable order, which is not defined")
+}
+
+func TestJsonPathValue(t *testing.T) {
+	content := `{"data": {"token": "t0k3n", "count": 3, "ok": true, "items": [{"id": 7}, {"id": 8.5}], "none": null}}`
+
+	data := []struct {
+		path     string
+		expected string
+	}{
+		{"$.data.token", "t0k3n"},
+		{"data.count", "3"},
+		{"$.data.ok", "true"},
+		{"$.data.items[1].id", "8.5"},
+		{"$.data.items.0", `{"id":7}`},
+		{"$.data.none", "null"},
+	}
+	for _, d := range data {
+		value, err := jsonPathValue(content, d.path)
+		assert.Nil(t, err)
+		assert.Equal(t, d.expected, value)
+	}
+
+	_, err := jsonPathValue(content, "$.data.items[2].id")
+	assert.EqualError(t, err, "'$.data.items[2].id' is not in the content")
+	_, err = jsonPathValue(content, "$.data..token")
+	assert.EqualError(t, err, "'$.data..token' is not a valid json path.")
+	_, err = jsonPathValue("<html>", "$.data")
+	assert.NotNil(t, err)
+}
+
+func TestRunCheckScenario(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server := journeyServer(t)
+	spec := CheckSpec{Url: server.URL + "/login", Steps: journeySteps(server.URL, "wrong")}
+
+	_, store, err := RunCheck(context.Background(), spec, "journey", nil, NewRecorder(0))
+	assert.Nil(t, err)
+	assert.Equal(t, FAIL, store.Data.Current.Status)
+	assert.Equal(t, "login", store.Data.Current.Step)
+	assert.Contains(t, ComposeTextMessage(spec.Url, &store.Data.Current), "FAILED STEP:        login\r\n")
+	assert.Contains(t, ComposeHtmlMessage(spec.Url, &store.Data.Current), "FAILED STEP")
+
+	spec.Steps = journeySteps(server.URL, "secret")
+	_, store, err = RunCheck(context.Background(), spec, "journey", nil, NewRecorder(0))
+	assert.Nil(t, err)
+	assert.Equal(t, PASS, store.Data.Current.Status)
+	assert.Equal(t, "", store.Data.Current.Step)
+}
+
+func TestLoadConfigScenario(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/pingu.json", []byte(`{
+		"monitors": [
+			{"store-name": "journey", "steps": [
+				{"name": "login", "url": "https://markgemmill.com/login", "form": {"user": "pingu"}, "extract": [{"name": "token", "json-path": "$.token"}]},
+				{"name": "api", "url": "https://markgemmill.com/api", "headers": {"Authorization": "Bearer ${token}"}, "expect-status": "2xx"}
+			]}
+		]
+	}`), 0644)
+
+	config, err := LoadConfig("/pingu.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://markgemmill.com/login", config.Monitors[0].Url)
+	assert.Equal(t, 2, len(config.Monitors[0].CheckSpec().Steps))
+
+	_ = afero.WriteFile(fs, "/bad-steps.json", []byte(`{"monitors": [{"steps": [{"url": "https://markgemmill.com/api/${token}"}]}]}`), 0644)
+	_, err = LoadConfig("/bad-steps.json")
+	assert.EqualError(t, err, "invalid config /bad-steps.json: monitor 1 step 'step 1' uses the variable token before it is extracted")
+}
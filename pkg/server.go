@@ -0,0 +1,308 @@
+package pkg
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/flosch/pongo2/v6"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+Server exposes the stores over http as a json api and html dashboard. The
+monitors of the config, which may be nil, give the checks run by the check
+api.
+*/
+type Server struct {
+	Options  ReportOptions
+	Config   *Config
+	User     string
+	Password string
+	console  Logger
+}
+
+func NewServer(options ReportOptions, config *Config, user, password string, console Logger) *Server {
+	return &Server{
+		Options:  options,
+		Config:   config,
+		User:     user,
+		Password: password,
+		console:  console,
+	}
+}
+
+// CheckResult is the response of an ad-hoc check. Current is the updated
+// record of the store if the check was saved.
+type CheckResult struct {
+	Url        string            `json:"url"`
+	StoreId    string            `json:"store-id"`
+	Status     string            `json:"status"`
+	Message    string            `json:"message"`
+	Assertions []AssertionResult `json:"assertions"`
+	Saved      bool              `json:"saved"`
+	Current    *StoreRecord      `json:"current"`
+}
+
+// Handler returns the http routes of the server:
+//
+//	GET  /                              html dashboard
+//	GET  /api/monitors                  summary of every monitor
+//	GET  /api/monitors/{id}             report and history of a monitor
+//	GET  /api/monitors/{id}/uptime      uptime statistics of a monitor
+//	POST /api/monitors/{id}/check       run a check of the monitor now, saved if ?save=true
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleDashboard)
+	mux.HandleFunc("/api/monitors", s.handleMonitors)
+	mux.HandleFunc("/api/monitors/", s.handleMonitor)
+
+	if s.User == "" && s.Password == "" {
+		return mux
+	}
+	return s.basicAuth(mux)
+}
+
+// ListenAndServe serves the api until the server fails.
+func (s *Server) ListenAndServe(listen string) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.console.Info("Listening on %s\n", listen)
+	return server.ListenAndServe()
+}
+
+func (s *Server) basicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(s.User)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pingu"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(content)
+	IgnoreOnError(err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, map[string]string{"error": err.Error()})
+}
+
+// reports builds the report of every store.
+func (s *Server) reports() ([]*Report, error) {
+	stores, err := ListStores()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reports := make([]*Report, 0, len(stores))
+	for _, store := range stores {
+		report := NewReport(store.Data, now)
+		err = report.Apply(s.Options)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	reports, err := s.reports()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]DigestSummary, 0, len(reports))
+	for _, report := range reports {
+		summaries = append(summaries, report.Summarize())
+	}
+
+	ctx := pongo2.Context{
+		"summaries": summaries,
+		"reports":   reports,
+		"generated": time.Now(),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write([]byte(RenderTemplate("dashboard.html", &ctx, false)))
+	IgnoreOnError(err)
+}
+
+func (s *Server) handleMonitors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	reports, err := s.reports()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	summaries := make([]DigestSummary, 0, len(reports))
+	for _, report := range reports {
+		summaries = append(summaries, report.Summarize())
+	}
+	writeJson(w, http.StatusOK, summaries)
+}
+
+func (s *Server) handleMonitor(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/monitors/"), "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	store, err := findStore(parts[0])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if store == nil {
+		writeError(w, http.StatusNotFound, errors.New(fmt.Sprintf("no monitor: %s", parts[0])))
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		s.handleReport(w, store, false)
+	case action == "uptime" && r.Method == http.MethodGet:
+		s.handleReport(w, store, true)
+	case action == "check" && r.Method == http.MethodPost:
+		s.handleCheck(w, r, store)
+	case action == "" || action == "uptime" || action == "check":
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, store *Store, uptimeOnly bool) {
+	report := NewReport(store.Data, time.Now())
+	err := report.Apply(s.Options)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if uptimeOnly {
+		writeJson(w, http.StatusOK, report.Uptime)
+		return
+	}
+	writeJson(w, http.StatusOK, report)
+}
+
+// monitor returns the configured monitor of the store, or nil.
+func (s *Server) monitor(store *Store) *MonitorConfig {
+	if s.Config == nil {
+		return nil
+	}
+	for i := range s.Config.Monitors {
+		if s.Config.Monitors[i].StoreId() == store.Name {
+			return &s.Config.Monitors[i]
+		}
+	}
+	return nil
+}
+
+/*
+handleCheck runs the configured check of the monitor, or a check for a 200
+status of its url if it is not in the config. The result is only saved to
+the store, as a scheduled check would, if the "save" query parameter is
+true. An unsaved check of a single url can change the expected status
+expression and content with the "status" and "content" query parameters,
+which a saved check does not allow.
+*/
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request, store *Store) {
+	query := r.URL.Query()
+	save := query.Get("save") == "true"
+
+	spec := CheckSpec{Url: store.Url, ExpectedStatus: "200"}
+	var tags map[string]string
+	monitor := s.monitor(store)
+	if monitor != nil {
+		spec = monitor.CheckSpec()
+		spec.Emitters = s.Config.MetricEmitters()
+		tags = monitor.Tags
+	}
+
+	if query.Get("status") != "" || query.Get("content") != "" {
+		if save {
+			writeError(w, http.StatusBadRequest, errors.New("a saved check cannot change the status or content expected"))
+			return
+		}
+		if len(spec.Steps) > 0 {
+			writeError(w, http.StatusBadRequest, errors.New("the status or content expected of a scenario cannot be changed"))
+			return
+		}
+		if query.Get("status") != "" {
+			spec.ExpectedStatus = StatusExpression(query.Get("status"))
+		}
+		if query.Get("content") != "" {
+			spec.ExpectedContent = query.Get("content")
+		}
+	}
+	err := spec.Validate()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	console := s.console.With(Fields{"monitor": store.Name, "url": store.Url})
+	var result Result
+	var updated *Store
+	if save {
+		result, updated, err = RunCheck(r.Context(), spec, store.Name, tags, console)
+	} else {
+		result, err = Check(r.Context(), spec)
+	}
+	if err != nil {
+		console.Debug("Check of %s failed: %s\n", store.Url, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := CheckResult{
+		Url:        store.Url,
+		StoreId:    store.Name,
+		Status:     result.Status(),
+		Message:    result.Message(),
+		Assertions: result.Assertions,
+		Saved:      save,
+	}
+	if updated != nil {
+		response.Current = &updated.Data.Current
+	}
+	writeJson(w, http.StatusOK, response)
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"encoding/json"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testServer(t *testing.T, user, password string) (*httptest.Server, *httptest.Server) {
+	return testConfigServer(t, user, password, nil)
+}
+
+func testConfigServer(t *testing.T, user, password string, config *Config) (*httptest.Server, *httptest.Server) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	t.Cleanup(func() { fs = original })
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("active"))
+	}))
+	if config != nil {
+		config.Monitors[0].Url = target.URL
+	}
+	t.Cleanup(target.Close)
+
+	store := NewStore(target.URL, "target")
+	store.Save(FAIL, "Could not fetch url.")
+	store.Write()
+
+	server := NewServer(ReportOptions{Windows: []string{"24h"}}, config, user, password, &Console{})
+	api := httptest.NewServer(server.Handler())
+	t.Cleanup(api.Close)
+
+	return api, target
+}
+
+func TestServerMonitors(t *testing.T) {
+	api, target := testServer(t, "", "")
+
+	resp, err := http.Get(api.URL + "/api/monitors")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	summaries := make([]DigestSummary, 0)
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&summaries))
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, target.URL, summaries[0].Url)
+	assert.Equal(t, FAIL, summaries[0].Status)
+}
+
+func TestServerMonitorDetail(t *testing.T) {
+	api, _ := testServer(t, "", "")
+
+	resp, _ := http.Get(api.URL + "/api/monitors/target")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	report := Report{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, "target", report.StoreId)
+
+	resp, _ = http.Get(api.URL + "/api/monitors/target/uptime")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	uptime := make([]UptimeStats, 0)
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&uptime))
+	assert.Equal(t, "24h", uptime[0].Window)
+
+	resp, _ = http.Get(api.URL + "/api/monitors/missing")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = http.Get(api.URL + "/api/monitors/target/check")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServerCheck(t *testing.T) {
+	api, target := testServer(t, "", "")
+
+	resp, err := http.Post(api.URL+"/api/monitors/target/check?content=active", "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	result := CheckResult{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, PASS, result.Status)
+	assert.False(t, result.Saved)
+	assert.Nil(t, result.Current)
+
+	resp, _ = http.Post(api.URL+"/api/monitors/target/check?status=202", "", nil)
+	result = CheckResult{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, FAIL, result.Status)
+	assert.Contains(t, result.Message, "expecting status of 202, but received 200")
+
+	resp, _ = http.Post(api.URL+"/api/monitors/target/check?status=!2xx", "", nil)
+	result = CheckResult{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Contains(t, result.Message, "expecting status other than 2xx, but received 200")
+
+	resp, _ = http.Post(api.URL+"/api/monitors/target/check?status=2zz", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// ad-hoc checks are not saved
+	store := NewStore(target.URL, "target")
+	store.Read()
+	assert.Equal(t, FAIL, store.Data.Current.Status)
+
+	// a saved check cannot change what is expected
+	resp, _ = http.Post(api.URL+"/api/monitors/target/check?save=true&status=500", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = http.Post(api.URL+"/api/monitors/target/check?save=true", "", nil)
+	result = CheckResult{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, PASS, result.Status)
+	assert.True(t, result.Saved)
+	assert.Equal(t, PASS, result.Current.Status)
+	store.Read()
+	assert.Equal(t, PASS, store.Data.Current.Status)
+}
+
+func TestServerCheckConfig(t *testing.T) {
+	config := &Config{Monitors: []MonitorConfig{{StoreName: "target", ExpectedStatus: "200", RejectContent: []string{"active"}}}}
+	api, _ := testConfigServer(t, "", "", config)
+
+	// the configured rules are checked
+	resp, _ := http.Post(api.URL+"/api/monitors/target/check", "", nil)
+	result := CheckResult{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, FAIL, result.Status)
+	assert.Contains(t, result.Message, "active")
+}
+
+func TestServerDashboard(t *testing.T) {
+	api, target := testServer(t, "", "")
+
+	resp, _ := http.Get(api.URL + "/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), target.URL)
+}
+
+func TestServerBasicAuth(t *testing.T) {
+	api, _ := testServer(t, "admin", "secret")
+
+	resp, _ := http.Get(api.URL + "/api/monitors")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodGet, api.URL+"/api/monitors", nil)
+	req.SetBasicAuth("admin", "wrong")
+	resp, _ = http.DefaultClient.Do(req)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req.SetBasicAuth("admin", "secret")
+	resp, _ = http.DefaultClient.Do(req)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
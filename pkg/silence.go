@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"strings"
+	"time"
+)
+
+/*
+Silence is a maintenance window started from the command line, during
+which the url is not checked. Silences are kept for SilenceRetention after
+they expire, so reports can show when and why the url was silenced. They
+are kept in a file of their own beside the store, so a check run by another
+process, which writes the store, cannot lose a silence started meanwhile.
+*/
+type Silence struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+// Active returns true if the given time is within the silence.
+func (s Silence) Active(given time.Time) bool {
+	return !given.Before(s.Start) && given.Before(s.End)
+}
+
+// Occurrences returns the silence if it overlaps the range.
+func (s Silence) Occurrences(from, to time.Time) []Interval {
+	if s.End.After(from) && s.Start.Before(to) {
+		return []Interval{{Start: s.Start, End: s.End}}
+	}
+	return []Interval{}
+}
+
+// ReasonText returns the reason of the silence, or "no reason given".
+func (s Silence) ReasonText() string {
+	if s.Reason == "" {
+		return "no reason given"
+	}
+	return s.Reason
+}
+
+func (s Silence) String() string {
+	return fmt.Sprintf(
+		"%s - %s %s",
+		s.Start.Format("2006-01-02 15:04"),
+		s.End.Format("2006-01-02 15:04"),
+		s.ReasonText(),
+	)
+}
+
+// ActiveSilence returns the silence active at the given time, or nil.
+func (s *StoreMaster) ActiveSilence(given time.Time) *Silence {
+	for i := range s.Silences {
+		if s.Silences[i].Active(given) {
+			return &s.Silences[i]
+		}
+	}
+	return nil
+}
+
+// SilenceRetention is how long a silence is kept in the store after it
+// ends, so reports of recent periods can still show it.
+const SilenceRetention = 90 * 24 * time.Hour
+
+// pruneSilences drops the silences that ended longer than SilenceRetention
+// before the given time.
+func (s *StoreMaster) pruneSilences(given time.Time) {
+	kept := make([]Silence, 0, len(s.Silences))
+	for _, silence := range s.Silences {
+		if given.Sub(silence.End) <= SilenceRetention {
+			kept = append(kept, silence)
+		}
+	}
+	s.Silences = kept
+}
+
+// silencesPath returns the path of the silences file of the store file.
+func silencesPath(storePath string) string {
+	return strings.TrimSuffix(storePath, "-log.json") + "-silences.json"
+}
+
+// readSilences reads the silences file of the store file, keeping the
+// silences read from the store file of an older version if there is none.
+func (s *StoreMaster) readSilences(storePath string) error {
+	content, err := afero.ReadFile(fs, silencesPath(storePath))
+	if err != nil {
+		exists, _ := afero.Exists(fs, silencesPath(storePath))
+		if !exists {
+			return nil
+		}
+		return err
+	}
+
+	silences := make([]Silence, 0)
+	err = json.Unmarshal(content, &silences)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid silences %s: %s", silencesPath(storePath), err))
+	}
+	s.Silences = silences
+	return nil
+}
+
+// writeSilences reads the silences of the store, updates them and writes
+// them back to the silences file, without writing the store itself.
+func (s *Store) writeSilences(update func()) {
+	lock := lockOf(s.Path)
+	lock.file.Lock()
+	defer lock.file.Unlock()
+
+	PanicOnError(s.Data.readSilences(s.Path))
+	update()
+
+	content, err := json.Marshal(s.Data.Silences)
+	PanicOnError(err)
+	err = afero.WriteFile(fs, silencesPath(s.Path), content, 0777)
+	PanicOnError(err)
+}
+
+// Silence stops the url being checked for the duration and writes the
+// silences, dropping the ones that ended long ago.
+func (s *Store) Silence(start time.Time, duration time.Duration, reason string) Silence {
+	s.Read()
+
+	silence := Silence{Start: start, End: start.Add(duration), Reason: reason}
+	s.writeSilences(func() {
+		s.Data.pruneSilences(start)
+		s.Data.Silences = append(s.Data.Silences, silence)
+	})
+	return silence
+}
+
+// Unsilence ends every silence active at the given time and writes the
+// silences. It returns the number of silences ended.
+func (s *Store) Unsilence(given time.Time) int {
+	s.Read()
+
+	ended := 0
+	s.writeSilences(func() {
+		for i := range s.Data.Silences {
+			if s.Data.Silences[i].Active(given) {
+				s.Data.Silences[i].End = given
+				ended += 1
+			}
+		}
+		if ended > 0 {
+			s.Data.pruneSilences(given)
+		}
+	})
+	return ended
+}
+
+/*
+FindStore returns the store of a url or store name. A url that has not been
+checked is given a new store, so a new monitor can be silenced before its
+first check. It returns an error if there is no store of the name.
+*/
+func FindStore(urlOrName string) (*Store, error) {
+	store, err := findStore(urlOrName)
+	if err != nil || store != nil {
+		return store, err
+	}
+
+	if strings.Contains(urlOrName, "://") {
+		store = NewStore(urlOrName, "")
+		store.Read()
+		return store, nil
+	}
+	return nil, errors.New(fmt.Sprintf("no store named '%s'", urlOrName))
+}
@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSilence(t *testing.T) {
+	silence := Silence{
+		Start:  time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 11, 3, 3, 0, 0, 0, time.UTC),
+		Reason: "db upgrade",
+	}
+
+	assert.False(t, silence.Active(time.Date(2026, 11, 3, 0, 59, 0, 0, time.UTC)))
+	assert.True(t, silence.Active(time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)))
+	assert.True(t, silence.Active(time.Date(2026, 11, 3, 2, 59, 0, 0, time.UTC)))
+	assert.False(t, silence.Active(time.Date(2026, 11, 3, 3, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2026-11-03 01:00 - 2026-11-03 03:00 db upgrade", silence.String())
+	assert.Equal(t, "no reason given", Silence{}.ReasonText())
+}
+
+func TestStoreSilence(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	now := time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)
+	store := NewStore("https://some.url.com", "some-url")
+	store.Read()
+	store.Silence(now, 2*time.Hour, "db upgrade")
+
+	saved := NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, "db upgrade", saved.Data.ActiveSilence(now.Add(time.Hour)).Reason)
+	// the silence expires on its own
+	assert.Nil(t, saved.Data.ActiveSilence(now.Add(2*time.Hour)))
+
+	assert.Equal(t, 1, saved.Unsilence(now.Add(30*time.Minute)))
+	assert.Equal(t, 0, saved.Unsilence(now.Add(45*time.Minute)))
+
+	saved = NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Nil(t, saved.Data.ActiveSilence(now.Add(time.Hour)))
+	assert.Equal(t, 1, len(saved.Data.Silences))
+	assert.Equal(t, now.Add(30*time.Minute), saved.Data.Silences[0].End.UTC())
+}
+
+func TestStoreSilencePrune(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	now := time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)
+	store := NewStore("https://some.url.com", "some-url")
+	store.Read()
+	store.Silence(now.Add(-SilenceRetention-2*time.Hour), time.Hour, "old")
+	store.Silence(now.Add(-time.Hour*24), time.Hour, "recent")
+
+	// the silence that ended long ago is dropped
+	store.Silence(now, time.Hour, "db upgrade")
+	saved := NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, 2, len(saved.Data.Silences))
+	assert.Equal(t, "recent", saved.Data.Silences[0].Reason)
+}
+
+func TestStoreSilenceKeepsChecks(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	now := time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)
+	store := NewStore("https://some.url.com", "some-url")
+	store.Read()
+
+	// a check saved after the store was read is not lost
+	checked := NewStore("https://some.url.com", "some-url")
+	checked.Read()
+	checked.Save(FAIL, "Could not fetch url.")
+	checked.Write()
+
+	store.Silence(now, time.Hour, "db upgrade")
+	saved := NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, FAIL, saved.Data.Current.Status)
+	assert.Equal(t, 1, len(saved.Data.Silences))
+}
+
+func TestStoreSilenceSurvivesChecks(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	now := time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)
+
+	// a check that read the store before the silence, as another process
+	// would, does not lose the silence when it writes the store
+	checked := NewStore("https://some.url.com", "some-url")
+	checked.Read()
+
+	store := NewStore("https://some.url.com", "some-url")
+	store.Silence(now, time.Hour, "db upgrade")
+
+	checked.Save(FAIL, "Could not fetch url.")
+	checked.Write()
+
+	saved := NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, FAIL, saved.Data.Current.Status)
+	assert.Equal(t, "db upgrade", saved.Data.ActiveSilence(now).Reason)
+
+	stores, err := ListStores()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(stores))
+	assert.Equal(t, "db upgrade", stores[0].Data.ActiveSilence(now).Reason)
+}
+
+func TestStoreSilenceOlderStore(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	// the silences of a store written by an older version are kept
+	now := time.Date(2026, 11, 3, 1, 0, 0, 0, time.UTC)
+	store := NewStore("https://some.url.com", "some-url")
+	content := `{"url": "https://some.url.com", "store-id": "some-url", "silences": [{"start": "2026-11-03T01:00:00Z", "end": "2026-11-03T02:00:00Z", "reason": "dns move"}]}`
+	assert.Nil(t, afero.WriteFile(fs, store.Path, []byte(content), 0777))
+
+	store.Read()
+	assert.Equal(t, "dns move", store.Data.ActiveSilence(now).Reason)
+	store.Silence(now.Add(time.Hour), time.Hour, "db upgrade")
+
+	saved := NewStore("https://some.url.com", "some-url")
+	saved.Read()
+	assert.Equal(t, 2, len(saved.Data.Silences))
+}
+
+func TestFindStore(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	store := NewStore("https://some.url.com", "some-url")
+	store.Read()
+
+	found, err := FindStore("some-url")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://some.url.com", found.Url)
+
+	found, err = FindStore("https://some.url.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "some-url", found.Name)
+
+	// a url that has not been checked is given a new store, which its
+	// first check uses
+	found, err = FindStore("https://other.url.com")
+	assert.Nil(t, err)
+	assert.Equal(t, NewStore("https://other.url.com", "").Path, found.Path)
+	exists, _ := afero.Exists(fs, found.Path)
+	assert.True(t, exists)
+	found, err = FindStore("https://other.url.com")
+	assert.Nil(t, err)
+	assert.Equal(t, NewStore("https://other.url.com", "").Name, found.Name)
+
+	_, err = FindStore("other-url")
+	assert.EqualError(t, err, "no store named 'other-url'")
+}
+
+func TestReportSilences(t *testing.T) {
+	store := testStoreMaster()
+	store.Silences = []Silence{
+		{Start: time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC), End: time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC), Reason: "dns move"},
+		{Start: time.Date(2022, 9, 2, 10, 0, 0, 0, time.UTC), End: time.Date(2022, 9, 2, 12, 0, 0, 0, time.UTC), Reason: "db upgrade"},
+	}
+
+	message := ReportMessage{Store: store}
+	assert.Nil(t, message.Initialize())
+	assert.Contains(t, message.ToText(), "2022-09-02 10:00:00 - 2022-09-02 12:00:00 db upgrade")
+	assert.Contains(t, message.ToMarkdown(), "| 2022-08-01 10:00:00 | 2022-08-01 11:00:00 | dns move |")
+	assert.Contains(t, message.ToHtml(), "db upgrade")
+
+	// silences outside the range are dropped
+	report := NewReport(store, time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC))
+	report.Clip(Interval{Start: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2022, 9, 3, 0, 0, 0, 0, time.UTC)})
+	assert.Equal(t, 1, len(report.Silences))
+	assert.Equal(t, "db upgrade", report.Silences[0].Reason)
+
+	// the digest names the silence active when it is generated
+	report = NewReport(store, time.Date(2022, 9, 2, 11, 0, 0, 0, time.UTC))
+	assert.Equal(t, "db upgrade", report.Summarize().Silenced.Reason)
+	report = NewReport(store, time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC))
+	assert.Nil(t, report.Summarize().Silenced)
+}
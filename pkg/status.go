@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+StatusExpression is the text of the accepted http status codes, a comma
+separated list of codes, classes and ranges, each of which may be negated:
+
+	200
+	200,204
+	2xx
+	200-299
+	!5xx
+	2xx,!204
+
+A code is accepted if it matches any of the terms that are not negated, or
+there are none, and none of the negated terms. It is read from json as
+either a number or a string.
+*/
+type StatusExpression string
+
+func (e *StatusExpression) UnmarshalJSON(data []byte) error {
+	text, err := unmarshalNumberOrString(data)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid status expression %s", string(data)))
+	}
+	*e = StatusExpression(text)
+	return nil
+}
+
+// Parse returns the set of status codes accepted by the expression.
+func (e StatusExpression) Parse() (*StatusSet, error) {
+	return ParseStatusSet(string(e))
+}
+
+// statusTerm is a single code, class or range of an expression.
+type statusTerm struct {
+	text   string
+	low    int
+	high   int
+	negate bool
+}
+
+func (t statusTerm) match(code int) bool {
+	return code >= t.low && code <= t.high
+}
+
+// StatusSet is a parsed StatusExpression.
+type StatusSet struct {
+	Expression StatusExpression
+	terms      []statusTerm
+}
+
+var statusClassRx = regexp.MustCompile(`^([1-5])[xX][xX]$`)
+var statusRangeRx = regexp.MustCompile(`^(\d{3})-(\d{3})$`)
+var statusCodeRx = regexp.MustCompile(`^\d{3}$`)
+
+func parseStatusTerm(text string) (statusTerm, error) {
+	term := statusTerm{text: text}
+	if strings.HasPrefix(text, "!") {
+		term.negate = true
+		text = strings.TrimSpace(text[1:])
+		term.text = text
+	}
+
+	if match := statusClassRx.FindStringSubmatch(text); match != nil {
+		class, _ := strconv.Atoi(match[1])
+		term.low = class * 100
+		term.high = class*100 + 99
+		term.text = match[1] + "xx"
+		return term, nil
+	}
+
+	if match := statusRangeRx.FindStringSubmatch(text); match != nil {
+		term.low, _ = strconv.Atoi(match[1])
+		term.high, _ = strconv.Atoi(match[2])
+		if term.low > term.high {
+			return term, errors.New(fmt.Sprintf("invalid status range '%s'", text))
+		}
+		return term, nil
+	}
+
+	if statusCodeRx.MatchString(text) {
+		term.low, _ = strconv.Atoi(text)
+		term.high = term.low
+		return term, nil
+	}
+
+	return term, errors.New(fmt.Sprintf("invalid status '%s', expecting a code, class or range such as 200, 2xx or 200-299", text))
+}
+
+func ParseStatusSet(expression string) (*StatusSet, error) {
+	set := &StatusSet{Expression: StatusExpression(expression)}
+	for _, text := range strings.Split(expression, ",") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		term, err := parseStatusTerm(text)
+		if err != nil {
+			return nil, err
+		}
+		set.terms = append(set.terms, term)
+	}
+	if len(set.terms) == 0 {
+		return nil, errors.New("empty status expression")
+	}
+	return set, nil
+}
+
+// NewStatusSet returns the set accepting only the status code.
+func NewStatusSet(code int) *StatusSet {
+	text := strconv.Itoa(code)
+	return &StatusSet{
+		Expression: StatusExpression(text),
+		terms:      []statusTerm{{text: text, low: code, high: code}},
+	}
+}
+
+// Match returns true if the set accepts the status code.
+func (s *StatusSet) Match(code int) bool {
+	accepted := true
+	for _, term := range s.terms {
+		if !term.negate {
+			accepted = false
+			break
+		}
+	}
+	for _, term := range s.terms {
+		if term.match(code) {
+			if term.negate {
+				return false
+			}
+			accepted = true
+		}
+	}
+	return accepted
+}
+
+func joinTerms(terms []string) string {
+	if len(terms) < 2 {
+		return strings.Join(terms, "")
+	}
+	return strings.Join(terms[:len(terms)-1], ", ") + " or " + terms[len(terms)-1]
+}
+
+// Describe returns the accepted codes as text, such as "of 200 or 204" or
+// "other than 5xx", to follow "expecting status".
+func (s *StatusSet) Describe() string {
+	accepted := make([]string, 0)
+	rejected := make([]string, 0)
+	for _, term := range s.terms {
+		if term.negate {
+			rejected = append(rejected, term.text)
+		} else {
+			accepted = append(accepted, term.text)
+		}
+	}
+
+	if len(accepted) == 0 {
+		return "other than " + joinTerms(rejected)
+	}
+	if len(rejected) == 0 {
+		return "of " + joinTerms(accepted)
+	}
+	return "of " + joinTerms(accepted) + " other than " + joinTerms(rejected)
+}
@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStatusSetMatch(t *testing.T) {
+	var tests = []struct {
+		expression string
+		accepted   []int
+		rejected   []int
+		describe   string
+	}{
+		{"200", []int{200}, []int{201, 404}, "of 200"},
+		{"200,204", []int{200, 204}, []int{201, 500}, "of 200 or 204"},
+		{"2xx", []int{200, 204, 299}, []int{199, 300, 503}, "of 2xx"},
+		{"2XX, 301", []int{200, 301}, []int{302}, "of 2xx or 301"},
+		{"200-299", []int{200, 250, 299}, []int{300}, "of 200-299"},
+		{"!5xx", []int{200, 404, 302}, []int{500, 503}, "other than 5xx"},
+		{"2xx,!204", []int{200, 201}, []int{204, 500}, "of 2xx other than 204"},
+		{"200,301,302", []int{302}, []int{303}, "of 200, 301 or 302"},
+	}
+
+	for _, tt := range tests {
+		set, err := ParseStatusSet(tt.expression)
+		assert.Nil(t, err, tt.expression)
+		for _, code := range tt.accepted {
+			assert.True(t, set.Match(code), "%s accepts %d", tt.expression, code)
+		}
+		for _, code := range tt.rejected {
+			assert.False(t, set.Match(code), "%s rejects %d", tt.expression, code)
+		}
+		assert.Equal(t, tt.describe, set.Describe())
+	}
+}
+
+func TestStatusSetInvalid(t *testing.T) {
+	for _, expression := range []string{"", "ok", "2zz", "20", "299-200", "6xx", "!"} {
+		_, err := ParseStatusSet(expression)
+		assert.NotNil(t, err, expression)
+	}
+}
+
+func TestStatusExpressionJson(t *testing.T) {
+	var monitor MonitorConfig
+	assert.Nil(t, json.Unmarshal([]byte(`{"expect-status": 204}`), &monitor))
+	assert.Equal(t, StatusExpression("204"), monitor.ExpectedStatus)
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"expect-status": "2xx,!204"}`), &monitor))
+	assert.Equal(t, StatusExpression("2xx,!204"), monitor.ExpectedStatus)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"expect-status": true}`), &monitor))
+}
+
+func TestStatusCodeAssertionSet(t *testing.T) {
+	set, _ := ParseStatusSet("200-299")
+	test := NewStatusSetAssertion(set)
+
+	pass, errMsg := test.Assert(&UrlResult{StatusCode: 503})
+	assert.False(t, pass)
+	assert.Equal(t, "expecting status of 200-299, but received 503", errMsg)
+	assert.Equal(t, "200-299", test.Expected())
+	assert.Equal(t, "503", test.Actual(&UrlResult{StatusCode: 503}))
+}
@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/flosch/pongo2/v6"
+	"github.com/spf13/afero"
+	"path"
+	"sort"
+	"time"
+)
+
+// StatusDay is a single daily uptime bar of the status page.
+type StatusDay struct {
+	Date        time.Time `json:"date"`
+	Monitored   bool      `json:"monitored"`
+	Uptime      float64   `json:"uptime"`
+	Downtime    float64   `json:"downtime"`
+	Maintenance float64   `json:"maintenance"`
+}
+
+// Class returns the css class of the uptime bar.
+func (d StatusDay) Class() string {
+	if !d.Monitored && d.Maintenance > 0 {
+		return "maint"
+	}
+	if !d.Monitored {
+		return "none"
+	}
+	if d.Uptime >= 100.0 {
+		return "up"
+	}
+	if d.Uptime >= 99.0 {
+		return "degraded"
+	}
+	return "down"
+}
+
+// Title returns the hover text of the uptime bar.
+func (d StatusDay) Title() string {
+	if !d.Monitored && d.Maintenance > 0 {
+		return d.Date.Format("2006-01-02") + ": maintenance"
+	}
+	if !d.Monitored {
+		return d.Date.Format("2006-01-02") + ": no data"
+	}
+	return d.Date.Format("2006-01-02") + ": " + UptimeStats{Uptime: d.Uptime}.UptimeText() + " uptime"
+}
+
+// StatusMonitor is the status page entry of a single monitor.
+type StatusMonitor struct {
+	Url     string      `json:"url"`
+	StoreId string      `json:"store-id"`
+	Status  string      `json:"status"`
+	Since   time.Time   `json:"since"`
+	Uptime  UptimeStats `json:"uptime"`
+	Days    []StatusDay `json:"days"`
+}
+
+// StatusPage is the model of the status page.
+type StatusPage struct {
+	Title     string           `json:"title"`
+	Generated time.Time        `json:"generated"`
+	Days      int              `json:"days"`
+	Monitors  []StatusMonitor  `json:"monitors"`
+	Incidents []StatusIncident `json:"incidents"`
+}
+
+// StatusIncident is a failure of a monitor listed in the recent incidents.
+type StatusIncident struct {
+	Url string `json:"url"`
+	ReportRecord
+}
+
+// AllUp checks if every monitor is currently passing.
+func (s *StatusPage) AllUp() bool {
+	for _, monitor := range s.Monitors {
+		if monitor.Status == FAIL {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+NewStatusPage builds the status page model from the stores, with a daily
+uptime bar for each of the given number of days up to the generated time,
+and at most maxIncidents of the most recent failures.
+*/
+func NewStatusPage(title string, stores []*StoreMaster, generated time.Time, days int, maxIncidents int, ignorePeriods []string) (*StatusPage, error) {
+	page := StatusPage{
+		Title:     title,
+		Generated: generated,
+		Days:      days,
+		Monitors:  make([]StatusMonitor, 0, len(stores)),
+		Incidents: make([]StatusIncident, 0),
+	}
+
+	today := time.Date(generated.Year(), generated.Month(), generated.Day(), 0, 0, 0, 0, generated.Location())
+	bounds := Interval{Start: today.AddDate(0, 0, 1-days), End: generated}
+
+	periods, err := GroupPeriods(GroupByDay, bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded, err := IgnorePeriodIntervals(ignorePeriods, bounds.Start, bounds.End)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, store := range stores {
+		report := NewReport(store, generated)
+		records := report.Records()
+
+		monitor := StatusMonitor{
+			Url:     report.Url,
+			StoreId: report.StoreId,
+			Status:  report.Current.Status,
+			Since:   report.Current.Start,
+			Uptime:  CalculateUptime(records, UptimeWindow{Label: fmt.Sprintf("%dd", days), Interval: bounds}, excluded, 0),
+			Days:    make([]StatusDay, 0, len(periods)),
+		}
+
+		for _, period := range periods {
+			stats := CalculateUptime(records, period, excluded, 0)
+			monitor.Days = append(monitor.Days, StatusDay{
+				Date:        period.Start,
+				Monitored:   stats.Monitored > 0,
+				Uptime:      stats.Uptime,
+				Downtime:    stats.Downtime,
+				Maintenance: stats.Maintenance,
+			})
+		}
+
+		for i := len(records) - 1; i >= 0; i-- {
+			if records[i].Status == FAIL && records[i].End.After(bounds.Start) {
+				page.Incidents = append(page.Incidents, StatusIncident{Url: report.Url, ReportRecord: records[i]})
+			}
+		}
+
+		page.Monitors = append(page.Monitors, monitor)
+	}
+
+	sort.Slice(page.Incidents, func(i, j int) bool {
+		return page.Incidents[i].Start.After(page.Incidents[j].Start)
+	})
+	if len(page.Incidents) > maxIncidents {
+		page.Incidents = page.Incidents[:maxIncidents]
+	}
+
+	return &page, nil
+}
+
+func (s *StatusPage) ToHtml() string {
+	ctx := pongo2.Context{
+		"page": s,
+	}
+	return RenderTemplate("statuspage.html", &ctx, false)
+}
+
+func (s *StatusPage) ToJson() string {
+	content, err := json.MarshalIndent(s, "", "  ")
+	PanicOnError(err)
+	return string(content) + "\n"
+}
+
+// Write saves the status page as index.html, along with status.json, to the
+// output directory.
+func (s *StatusPage) Write(outDir string) error {
+	err := fs.MkdirAll(outDir, 0777)
+	if err != nil {
+		return err
+	}
+
+	err = afero.WriteFile(fs, path.Join(outDir, "index.html"), []byte(s.ToHtml()), 0644)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, path.Join(outDir, "status.json"), []byte(s.ToJson()), 0644)
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewStatusPage(t *testing.T) {
+	generated := time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC)
+	page, err := NewStatusPage("Status", []*StoreMaster{testStoreMaster()}, generated, 5, 10, nil)
+	assert.Nil(t, err)
+
+	assert.True(t, page.AllUp())
+	assert.Equal(t, 1, len(page.Monitors))
+
+	days := page.Monitors[0].Days
+	assert.Equal(t, 5, len(days))
+	assert.Equal(t, time.Date(2022, 8, 30, 0, 0, 0, 0, time.UTC), days[0].Date)
+	assert.Equal(t, "none", days[0].Class())
+	assert.Equal(t, "up", days[2].Class())
+	assert.Equal(t, "down", days[3].Class())
+	assert.Equal(t, "up", days[4].Class())
+
+	assert.Equal(t, 1, len(page.Incidents))
+	assert.Equal(t, "https://markgemmill.com", page.Incidents[0].Url)
+}
+
+func TestStatusPageWrite(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	generated := time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC)
+	page, _ := NewStatusPage("Pingu Status", []*StoreMaster{testStoreMaster()}, generated, 90, 10, nil)
+
+	assert.Nil(t, page.Write("/public"))
+
+	html, err := afero.ReadFile(fs, "/public/index.html")
+	assert.Nil(t, err)
+	assert.Contains(t, string(html), "<title>Pingu Status</title>")
+	assert.Equal(t, 90, strings.Count(string(html), `<div class="bar `))
+
+	exists, _ := afero.Exists(fs, "/public/status.json")
+	assert.True(t, exists)
+}
+
+func TestStatusPageTemplateOverride(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	_ = afero.WriteFile(fs, "/templates/statuspage.html", []byte("<h1>{{ page.Title }}</h1>"), 0644)
+	SetTemplateDirectory("/templates")
+	defer SetTemplateDirectory("")
+
+	generated := time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC)
+	page, _ := NewStatusPage("Custom", []*StoreMaster{testStoreMaster()}, generated, 90, 10, nil)
+
+	assert.Equal(t, "<h1>Custom</h1>", page.ToHtml())
+}
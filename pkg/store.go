@@ -3,16 +3,22 @@ package pkg
 import (
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/spf13/afero"
 	"path"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 const PASS = "PASS"
 const FAIL = "FAIL"
 
+// MAINT is the status of the checks ignored during a maintenance window.
+const MAINT = "MAINT"
+
 // StoreMaster holds the current and historic StoreRecords.
 type StoreMaster struct {
 	Url      string        `json:"url"`
@@ -20,6 +26,14 @@ type StoreMaster struct {
 	Current  StoreRecord   `json:"current"`
 	Failures []StoreRecord `json:"failures"`
 	Passes   []StoreRecord `json:"passes"`
+	// Maintenance holds the records of checks ignored during maintenance windows.
+	Maintenance []StoreRecord `json:"maintenance,omitempty"`
+	Silences    []Silence     `json:"silences,omitempty"`
+	// Flaps holds the periods during which the status kept changing.
+	Flaps []FlapPeriod `json:"flaps,omitempty"`
+	// Held holds the failures whose alerts were held outside business hours,
+	// until they are sent in a digest.
+	Held []StoreRecord `json:"held,omitempty"`
 }
 
 func NewStoreMaster(url, storeId string) *StoreMaster {
@@ -67,25 +81,144 @@ func NewStore(url, name string) *Store {
 	}
 }
 
+// storeLock guards the file of a store, see Store.Lock.
+type storeLock struct {
+	file  sync.RWMutex
+	check sync.Mutex
+}
+
+var storeLocks = struct {
+	mu    sync.Mutex
+	paths map[string]*storeLock
+}{paths: make(map[string]*storeLock)}
+
+// lockOf returns the lock of the store file.
+func lockOf(path string) *storeLock {
+	storeLocks.mu.Lock()
+	defer storeLocks.mu.Unlock()
+	lock, ok := storeLocks.paths[path]
+	if !ok {
+		lock = &storeLock{}
+		storeLocks.paths[path] = lock
+	}
+	return lock
+}
+
+/*
+Lock stops any other check of the store in this process until Unlock, so a
+check can read, update and write the store without losing the update of a
+check run at the same time. Read and Write are always safe to call, as
+they never overlap a write of the same file.
+*/
+func (s *Store) Lock() {
+	lockOf(s.Path).check.Lock()
+}
+
+func (s *Store) Unlock() {
+	lockOf(s.Path).check.Unlock()
+}
+
+// storePattern matches the file names of all stores in the data directory.
+const storePattern = "pingu-*-log.json"
+
+// ListStores reads every store in the data directory, ordered by url.
+func ListStores() ([]*Store, error) {
+	paths, err := afero.Glob(fs, path.Join(dirs.UserDataDir(), storePattern))
+	if err != nil {
+		return nil, err
+	}
+
+	stores := make([]*Store, 0, len(paths))
+	for _, p := range paths {
+		lock := lockOf(p)
+		lock.file.RLock()
+		content, err := afero.ReadFile(fs, p)
+		lock.file.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		data := StoreMaster{}
+		err = json.Unmarshal(content, &data)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid store %s: %s", p, err))
+		}
+		lock.file.RLock()
+		err = data.readSilences(p)
+		lock.file.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		stores = append(stores, &Store{
+			Url:  data.Url,
+			Name: data.StoreId,
+			Path: p,
+			Data: &data,
+		})
+	}
+
+	sort.Slice(stores, func(i, j int) bool {
+		if stores[i].Url == stores[j].Url {
+			return stores[i].Name < stores[j].Name
+		}
+		return stores[i].Url < stores[j].Url
+	})
+
+	return stores, nil
+}
+
+// findStore returns the store of a url or store name, or nil if there is none.
+func findStore(urlOrName string) (*Store, error) {
+	stores, err := ListStores()
+	if err != nil {
+		return nil, err
+	}
+	for _, store := range stores {
+		if store.Url == urlOrName || store.Name == urlOrName {
+			return store, nil
+		}
+	}
+	return nil, nil
+}
+
 func (s *Store) Read() {
+	lock := lockOf(s.Path)
+	lock.file.Lock()
+	defer lock.file.Unlock()
+
 	/// first check if the file exists, and create it if it doesn't
 	exists, err := afero.Exists(fs, s.Path)
 	PanicOnError(err)
 
 	if !exists {
-		s.Write()
+		s.write()
 		return
 	}
 
 	content, err := afero.ReadFile(fs, s.Path)
 	PanicOnError(err)
 
+	// a field left out of the file, such as an empty held list, must not
+	// keep the value of an earlier read
+	*s.Data = StoreMaster{}
 	err = json.Unmarshal(content, s.Data)
 	PanicOnError(err)
+	PanicOnError(s.Data.readSilences(s.Path))
 }
 
 func (s *Store) Write() {
-	content, err := json.Marshal(s.Data)
+	lock := lockOf(s.Path)
+	lock.file.Lock()
+	defer lock.file.Unlock()
+	s.write()
+}
+
+func (s *Store) write() {
+	// the silences are written to their own file, see Silence
+	data := *s.Data
+	data.Silences = nil
+	content, err := json.Marshal(data)
 	PanicOnError(err)
 
 	err = afero.WriteFile(fs, s.Path, content, 0777)
@@ -98,7 +231,7 @@ func (s *Store) Write() {
 // StoreRecord for the current check failure.
 //
 // We toggle records between changes in status.
-// There are 2 status' PASS and FAIL.
+// There are 3 status' PASS, FAIL and MAINT.
 // When there is a change, we stash the current status to history,
 // and start a new status.
 func (s *Store) Save(status, message string) {
@@ -110,7 +243,15 @@ func (s *Store) Save(status, message string) {
 		s.Data.Current.Last = currentTimestamp
 		s.Data.Current.Message = message
 	} else {
-		if status == PASS {
+		if s.Data.Current.Status == MAINT {
+			s.Data.Maintenance = append(s.Data.Maintenance, s.Data.Current)
+		} else if status == MAINT {
+			if s.Data.Current.Status == PASS {
+				s.Data.Passes = append(s.Data.Passes, s.Data.Current)
+			} else if s.Data.Current.Status == FAIL {
+				s.Data.Failures = append(s.Data.Failures, s.Data.Current)
+			}
+		} else if status == PASS {
 			s.Data.Failures = append(s.Data.Failures, s.Data.Current)
 		} else if status == FAIL {
 			s.Data.Passes = append(s.Data.Passes, s.Data.Current)
@@ -125,3 +266,73 @@ func (s *Store) Save(status, message string) {
 		}
 	}
 }
+
+// Record saves the result of a check, along with its redirect chain and
+// failed scenario step, and writes the store.
+func (s *Store) Record(result Result) {
+	s.Save(result.Status(), result.Message())
+	s.Data.Current.Redirects = nil
+	if len(result.Response.Redirects) > 0 {
+		s.Data.Current.Redirects = result.Response.Redirects
+	}
+	s.Data.Current.Step = result.FailedStep
+	s.Data.Current.Failures = nil
+	if failures := result.Failures(); len(failures) > 0 {
+		s.Data.Current.Failures = failures
+	}
+	s.Write()
+}
+
+// SuppressAlert marks the current failure as having its alert suppressed
+// and writes the store.
+func (s *Store) SuppressAlert() {
+	if s.Data.Current.Status != FAIL {
+		return
+	}
+	s.Data.Current.Suppressed = true
+	s.Write()
+}
+
+// HoldAlert suppresses the alert of the current failure outside business
+// hours and queues the failure for the digest, then writes the store.
+func (s *Store) HoldAlert() {
+	if s.Data.Current.Status != FAIL {
+		return
+	}
+	s.Data.Current.Suppressed = true
+	held := len(s.Data.Held)
+	if held > 0 && s.Data.Held[held-1].Start.Equal(s.Data.Current.Start) {
+		s.Data.Held[held-1] = s.Data.Current
+	} else {
+		s.Data.Held = append(s.Data.Held, s.Data.Current)
+	}
+	s.Write()
+}
+
+// DigestSent clears the failures held for the digest and writes the store.
+func (s *Store) DigestSent() {
+	if len(s.Data.Held) == 0 {
+		return
+	}
+	s.Data.Held = nil
+	s.Write()
+}
+
+// AlertSent clears the suppressed alert of the current failure and writes
+// the store.
+func (s *Store) AlertSent() {
+	if !s.Data.Current.Suppressed {
+		return
+	}
+	s.Data.Current.Suppressed = false
+	s.Write()
+}
+
+// Maintain saves a MAINT status, for a check ignored during a maintenance
+// window, and writes the store.
+func (s *Store) Maintain(message string) {
+	s.Save(MAINT, message)
+	s.Data.Current.Redirects = nil
+	s.Data.Current.Step = ""
+	s.Write()
+}
@@ -1,10 +1,142 @@
 package pkg
 
 import (
+	"context"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 )
 
 func TestSluggifyUrl(t *testing.T) {
 	assert.Equal(t, "https_markgemmill_com", sluggifyUrl("https://markgemmill.com"))
 }
+
+func TestListStores(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	second := NewStore("https://markgemmill.com/status", "")
+	second.Save(PASS, "")
+	second.Write()
+
+	first := NewStore("https://markgemmill.com", "home")
+	first.Save(FAIL, "Could not fetch url.")
+	first.Write()
+
+	stores, err := ListStores()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stores))
+	assert.Equal(t, "https://markgemmill.com", stores[0].Url)
+	assert.Equal(t, "home", stores[0].Name)
+	assert.Equal(t, FAIL, stores[0].Data.Current.Status)
+	assert.Equal(t, "https://markgemmill.com/status", stores[1].Url)
+}
+
+func TestStoreSaveMaintenance(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	store := NewStore("https://markgemmill.com", "home")
+	store.Save(PASS, "")
+	store.Maintain("ignore period: SAT 22:00 - SUN 01:00")
+	store.Maintain("ignore period: SAT 22:00 - SUN 01:00")
+	store.Save(FAIL, "Could not fetch url.")
+	store.Maintain("silenced: db upgrade")
+	store.Save(PASS, "")
+
+	assert.Equal(t, PASS, store.Data.Current.Status)
+	assert.Equal(t, 2, len(store.Data.Maintenance))
+	assert.Equal(t, int64(2), store.Data.Maintenance[0].Count)
+	assert.Equal(t, "silenced: db upgrade", store.Data.Maintenance[1].Message)
+	assert.Equal(t, FAIL, store.Data.Failures[len(store.Data.Failures)-1].Status)
+	assert.Equal(t, PASS, store.Data.Passes[len(store.Data.Passes)-1].Status)
+
+	report := NewReport(store.Data, store.Data.Current.Last)
+	statuses := make([]string, 0)
+	for _, record := range report.Records() {
+		statuses = append(statuses, record.Status)
+	}
+	assert.Equal(t, []string{PASS, MAINT, FAIL, MAINT, PASS}, statuses)
+	assert.Equal(t, "MAINTENANCE", report.History[1].StatusText())
+}
+
+func TestRecordMaintenance(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(0)
+	store, err := RecordMaintenance(context.Background(), CheckSpec{Url: server.URL}, "maint", "ignore period: 22:00 - 23:00", false, recorder)
+	assert.Nil(t, err)
+	assert.Equal(t, MAINT, store.Data.Current.Status)
+	assert.Equal(t, "ignore period: 22:00 - 23:00", store.Data.Current.Message)
+	assert.Equal(t, "check.maint", recorder.Events()[0].Event)
+
+	store, err = RecordMaintenance(context.Background(), CheckSpec{Url: server.URL}, "maint", "ignore period: 22:00 - 23:00", true, recorder)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), store.Data.Current.Count)
+	assert.Equal(t, "ignore period: 22:00 - 23:00; check FAIL; expecting status of 200, but received 503; ", store.Data.Current.Message)
+}
+
+func TestStoreSuppressAlert(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	store := NewStore("https://markgemmill.com", "home")
+	store.Save(PASS, "")
+	store.SuppressAlert()
+	assert.False(t, store.Data.Current.Suppressed)
+
+	store.Save(FAIL, "Could not fetch url.")
+	store.SuppressAlert()
+	store.Save(FAIL, "Could not fetch url.")
+	assert.True(t, store.Data.Current.Suppressed)
+
+	saved := NewStore("https://markgemmill.com", "home")
+	saved.Read()
+	assert.True(t, saved.Data.Current.Suppressed)
+
+	saved.AlertSent()
+	assert.False(t, saved.Data.Current.Suppressed)
+
+	// a new failure starts without a suppressed alert
+	store.Save(PASS, "")
+	store.Save(FAIL, "Could not fetch url.")
+	assert.False(t, store.Data.Current.Suppressed)
+}
+
+func TestStoreLock(t *testing.T) {
+	original := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// checks of the same store at once do not lose each others updates
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := RunCheck(context.Background(), CheckSpec{Url: server.URL}, "shared", nil, NewRecorder(0))
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	store := NewStore(server.URL, "shared")
+	store.Read()
+	assert.Equal(t, int64(20), store.Data.Current.Count)
+}
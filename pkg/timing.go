@@ -16,19 +16,53 @@ func CalculatePauseInSeconds(retry, increment int) time.Duration {
 }
 
 /*
-DayTime struct represents a time on a given day of the week.
+DayTime struct represents a time on a given day of the week. If Zone is
+set, the time is in that time zone, otherwise it is in the zone of the
+time it is compared with.
 */
 type DayTime struct {
 	Dow    string
 	Hour   int
 	Minute int
 	AmPm   string
+	Zone   *time.Location
 }
 
 func (d DayTime) String() string {
+	if d.Zone != nil {
+		return fmt.Sprintf("%s %d:%d %s", d.Dow, d.twentyFour(), d.Minute, d.Zone)
+	}
 	return fmt.Sprintf("%s %d:%d", d.Dow, d.twentyFour(), d.Minute)
 }
 
+// in returns the given time in the zone of the DayTime.
+func (d DayTime) in(given time.Time) time.Time {
+	return inZone(d.Zone, given)
+}
+
+// inZone returns the given time in the zone, or unchanged if zone is nil.
+func inZone(zone *time.Location, given time.Time) time.Time {
+	if zone == nil {
+		return given
+	}
+	return given.In(zone)
+}
+
+// zonePattern matches the optional time zone that ends a time period.
+const zonePattern = `(?: (?P<zone>[A-Za-z][A-Za-z0-9_+\-]*(?:/[A-Za-z0-9_+\-]+)*))?`
+
+// loadZone returns the named time zone, or nil if name is empty.
+func loadZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("'%s' is an unknown time zone.", name))
+	}
+	return location, nil
+}
+
 // twentyFour returns the 24-hour version of the hour.
 func (d DayTime) twentyFour() int {
 	if d.AmPm == "PM" {
@@ -48,6 +82,7 @@ specify a day of the week, then it will be the same as the current given
 day of the week.
 */
 func (d DayTime) priorToDate(given time.Time) (time.Time, error) {
+	given = d.in(given)
 	var days = []int{0, -1, -2, -3, -4, -5, -6}
 	for _, i := range days {
 		cd := given.AddDate(0, 0, i)
@@ -71,6 +106,7 @@ specify a day of the week, then it will be the same as the current given
 day of the week.
 */
 func (d DayTime) afterDate(given time.Time) (time.Time, error) {
+	given = d.in(given)
 	var days = []int{0, 1, 2, 3, 4, 5, 6}
 	for _, i := range days {
 		cd := given.AddDate(0, 0, i)
@@ -88,11 +124,11 @@ func (d DayTime) afterDate(given time.Time) (time.Time, error) {
 
 func NewDayTime(day, hour, minute, amPm string) (DayTime, error) {
 
-	hr, err := strconv.ParseInt(hour, 0, 64)
+	hr, err := strconv.ParseInt(hour, 10, 64)
 	if err != nil {
 		return DayTime{}, errors.New(fmt.Sprintf("'%s' is invalid hour value.", hour))
 	}
-	mn, err := strconv.ParseInt(minute, 0, 64)
+	mn, err := strconv.ParseInt(minute, 10, 64)
 	if err != nil {
 		return DayTime{}, errors.New(fmt.Sprintf("'%s' is invalid minute value.", minute))
 	}
@@ -150,6 +186,14 @@ func (t *TimePeriod) InTimePeriod() bool {
 		(t.endTime.Equal(t.current) || t.endTime.After(t.current))
 }
 
+// Active returns true if the given time is within the time period.
+func (t *TimePeriod) Active(given time.Time) bool {
+	t.Init(given)
+	return t.InTimePeriod()
+}
+
+var timePeriodRx = regexp.MustCompile(`^(?P<beginDay>MON|TUE|WED|THU|FRI|SAT|SUN)? ?(?P<beginHr>\d{1,2}):(?P<beginMin>\d\d) ?(?P<beginAM>AM|PM)? ?- ?(?P<endDay>MON|TUE|WED|THU|FRI|SAT|SUN)? ?(?P<endHr>\d{1,2}):(?P<endMin>\d\d) ?(?P<endAM>AM|PM)?` + zonePattern + `$`)
+
 /*
 ParseTimePeriod accepts a string in the format and returns DayTime objects
 representing the start and ending of the time period:
@@ -162,15 +206,19 @@ AM can be either AM or PM - if omitted, then expect the HH:MM to be 24 hr.
 
 DOW can be omitted which would indicate the date would be daily.
 
+The period can end with an IANA time zone name, such as UTC or
+America/Toronto, in which case the times are in that zone and follow its
+daylight saving changes. Otherwise they are in the local time of the
+time checked.
+
 Examples:
 
 	SAT 23:00 - SUN 01:00
 	SAT 11:00 PM - SUN 01:00 AM
+	SAT 22:00 - SUN 01:00 America/Toronto
 */
 func ParseTimePeriod(timeoutStatement string) (DayTime, DayTime, error) {
-
-	rx := `^(?P<beginDay>MON|TUE|WED|THU|FRI|SAT|SUN)? ?(?P<beginHr>\d{1,2}):(?P<beginMin>\d\d) ?(?P<beginAM>AM|PM)? ?- ?(?P<endDay>MON|TUE|WED|THU|FRI|SAT|SUN)? ?(?P<endHr>\d{1,2}):(?P<endMin>\d\d) ?(?P<endAM>AM|PM)?$`
-	re := regexp.MustCompile(rx)
+	re := timePeriodRx
 
 	match := re.FindStringSubmatch(timeoutStatement)
 	if len(match) == 0 {
@@ -201,12 +249,62 @@ func ParseTimePeriod(timeoutStatement string) (DayTime, DayTime, error) {
 		return beginning, DayTime{}, err
 	}
 
+	zone, err := loadZone(match[mapping["zone"]])
+	if err != nil {
+		return beginning, ending, err
+	}
+	beginning.Zone = zone
+	ending.Zone = zone
+
 	return beginning, ending, nil
 }
 
+/*
+Occurrences returns the concrete start and end times of every instance of
+the time period that overlaps the given range.
+*/
+func (t *TimePeriod) Occurrences(from, to time.Time) []Interval {
+	occurrences := make([]Interval, 0)
+
+	from = t.starts.in(from)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, -7)
+	for !day.After(to) {
+		dow := strings.ToUpper(day.Weekday().String())
+		if t.starts.Dow == "" || strings.HasPrefix(dow, t.starts.Dow) {
+			start := time.Date(day.Year(), day.Month(), day.Day(), t.starts.twentyFour(), t.starts.Minute, 0, 0, day.Location())
+			end, _ := t.ends.afterDate(start)
+			if end.Before(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			if end.After(from) && start.Before(to) {
+				occurrences = append(occurrences, Interval{Start: start, End: end})
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return occurrences
+}
+
+// IsIgnorePeriodActive returns true if the maintenance window is active at
+// the current time. An invalid window is never active.
 func IsIgnorePeriodActive(ignorePeriodStr string, currentTime time.Time) bool {
-	b, e, _ := ParseTimePeriod(ignorePeriodStr)
-	to := NewTimeout(b, e)
-	to.Init(currentTime)
-	return to.InTimePeriod()
+	window, err := ParseMaintenanceWindow(ignorePeriodStr)
+	if err != nil {
+		return false
+	}
+	return window.Active(currentTime)
+}
+
+// IgnorePeriodIntervals returns every occurrence of the ignore periods within the given range.
+func IgnorePeriodIntervals(ignorePeriods []string, from, to time.Time) ([]Interval, error) {
+	intervals := make([]Interval, 0)
+	for _, ignorePeriodStr := range ignorePeriods {
+		window, err := ParseMaintenanceWindow(ignorePeriodStr)
+		if err != nil {
+			return intervals, err
+		}
+		intervals = append(intervals, window.Occurrences(from, to)...)
+	}
+	return intervals, nil
 }
@@ -66,6 +66,11 @@ func TestParseTimeout(t *testing.T) {
 	assertDayTime(t, b, "", "", 10, 30)
 	assertDayTime(t, e, "", "", 12, 0)
 
+	// leading zeros are decimal
+	b, e, _ = ParseTimePeriod("08:09 - 09:08")
+	assertDayTime(t, b, "", "", 8, 9)
+	assertDayTime(t, e, "", "", 9, 8)
+
 	b, e, _ = ParseTimePeriod("TUE 10:00 PM - WED 12:00 AM")
 	assertDayTime(t, b, "TUE", "PM", 10, 0)
 	assertDayTime(t, e, "WED", "AM", 12, 0)
@@ -207,3 +212,91 @@ func TestTimeout(t *testing.T) {
 	assertInTimeout(t, "SAT 10:00PM - SUN 1:00AM", 16, 1, 1, false)
 
 }
+
+func assertInZonedTimeout(t *testing.T, timeout string, given time.Time, expectedResult bool) {
+	result := IsIgnorePeriodActive(timeout, given)
+	assert.Equal(t, expectedResult, result, fmt.Sprintf("Expected %s in '%s' to be %v, but got %v\n", given.Format(time.RFC3339), timeout, expectedResult, result))
+}
+
+func TestParseTimePeriodZone(t *testing.T) {
+	b, e, err := ParseTimePeriod("SAT 22:00 - SUN 01:00 America/Toronto")
+	assert.Nil(t, err)
+	assertDayTime(t, b, "SAT", "", 22, 0)
+	assertDayTime(t, e, "SUN", "", 1, 0)
+	assert.Equal(t, "America/Toronto", b.Zone.String())
+	assert.Equal(t, "America/Toronto", e.Zone.String())
+
+	b, _, err = ParseTimePeriod("SAT 10:00PM - SUN 1:00AM UTC")
+	assert.Nil(t, err)
+	assert.Equal(t, time.UTC.String(), b.Zone.String())
+
+	b, _, err = ParseTimePeriod("SAT 10:00PM - SUN 1:00AM")
+	assert.Nil(t, err)
+	assert.Nil(t, b.Zone)
+
+	_, _, err = ParseTimePeriod("SAT 22:00 - SUN 01:00 Mars/Olympus")
+	assert.EqualError(t, err, "'Mars/Olympus' is an unknown time zone.")
+}
+
+func TestTimeoutZone(t *testing.T) {
+	period := "SAT 22:00 - SUN 01:00 America/Toronto"
+
+	// standard time, UTC-5
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 6, 2, 59, 0, 0, time.UTC), false)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 6, 3, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 6, 6, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 6, 6, 1, 0, 0, time.UTC), false)
+
+	// daylight saving time, UTC-4
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 20, 1, 59, 0, 0, time.UTC), false)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 20, 2, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 20, 5, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 20, 5, 1, 0, 0, time.UTC), false)
+
+	// the given time zone is irrelevant
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 20, 11, 30, 0, 0, tokyo), true)
+	assertInZonedTimeout(t, period, time.Date(2022, 3, 20, 10, 30, 0, 0, tokyo), false)
+}
+
+func TestTimeoutZoneDstTransitions(t *testing.T) {
+	// clocks spring forward from 02:00 to 03:00 on 2022-03-13, so the
+	// period is only two hours long.
+	spring := "SUN 01:00 - SUN 04:00 America/Toronto"
+	assertInZonedTimeout(t, spring, time.Date(2022, 3, 13, 5, 59, 0, 0, time.UTC), false)
+	assertInZonedTimeout(t, spring, time.Date(2022, 3, 13, 6, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, spring, time.Date(2022, 3, 13, 7, 30, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, spring, time.Date(2022, 3, 13, 8, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, spring, time.Date(2022, 3, 13, 8, 1, 0, 0, time.UTC), false)
+
+	// clocks fall back from 02:00 to 01:00 on 2022-11-06, so the period is
+	// four hours long and includes both 01:30s.
+	fall := "SUN 00:00 - SUN 03:00 America/Toronto"
+	assertInZonedTimeout(t, fall, time.Date(2022, 11, 6, 3, 59, 0, 0, time.UTC), false)
+	assertInZonedTimeout(t, fall, time.Date(2022, 11, 6, 4, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, fall, time.Date(2022, 11, 6, 5, 30, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, fall, time.Date(2022, 11, 6, 6, 30, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, fall, time.Date(2022, 11, 6, 8, 0, 0, 0, time.UTC), true)
+	assertInZonedTimeout(t, fall, time.Date(2022, 11, 6, 8, 1, 0, 0, time.UTC), false)
+}
+
+func TestIgnorePeriodIntervalsZone(t *testing.T) {
+	toronto, _ := time.LoadLocation("America/Toronto")
+	from := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 3, 22, 0, 0, 0, 0, time.UTC)
+
+	intervals, err := IgnorePeriodIntervals([]string{"SAT 22:00 - SUN 01:00 America/Toronto"}, from, to)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(intervals))
+	for _, interval := range intervals {
+		assert.Equal(t, 22, interval.Start.In(toronto).Hour())
+		assert.Equal(t, 1, interval.End.In(toronto).Hour())
+	}
+	assert.Equal(t, time.Date(2022, 3, 6, 3, 0, 0, 0, time.UTC), intervals[0].Start.UTC())
+	assert.Equal(t, 3*time.Hour, intervals[0].End.Sub(intervals[0].Start))
+	// the offset changes between the second and third periods
+	assert.Equal(t, time.Date(2022, 3, 13, 3, 0, 0, 0, time.UTC), intervals[1].Start.UTC())
+	assert.Equal(t, 3*time.Hour, intervals[1].End.Sub(intervals[1].Start))
+	assert.Equal(t, time.Date(2022, 3, 20, 2, 0, 0, 0, time.UTC), intervals[2].Start.UTC())
+	assert.Equal(t, 3*time.Hour, intervals[2].End.Sub(intervals[2].Start))
+}
@@ -0,0 +1,288 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UptimeWindow is a named range of time over which uptime is calculated.
+type UptimeWindow struct {
+	Label string
+	Interval
+}
+
+/*
+ParseUptimeWindow accepts a window definition and returns the range
+it covers ending at the given time. A window is either a duration
+with an h, d or w suffix, or the word "month" for the calendar month
+to date.
+
+Examples:
+
+	24h
+	7d
+	2w
+	month
+*/
+func ParseUptimeWindow(window string, now time.Time) (UptimeWindow, error) {
+	window = strings.ToLower(strings.TrimSpace(window))
+
+	if window == "month" {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return UptimeWindow{Label: window, Interval: Interval{Start: start, End: now}}, nil
+	}
+
+	d, err := ParseDays(window)
+	if err != nil {
+		return UptimeWindow{}, errors.New(fmt.Sprintf("'%s' is an invalid uptime window.", window))
+	}
+
+	return UptimeWindow{Label: window, Interval: Interval{Start: now.Add(-d), End: now}}, nil
+}
+
+/*
+ParseDays parses a duration like time.ParseDuration but also accepts
+a whole number of days (d) or weeks (w).
+*/
+func ParseDays(text string) (time.Duration, error) {
+	re := regexp.MustCompile(`^(\d+)(d|w)$`)
+	match := re.FindStringSubmatch(text)
+	if len(match) == 0 {
+		return time.ParseDuration(text)
+	}
+
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	days := time.Duration(n) * 24 * time.Hour
+	if match[2] == "w" {
+		days *= 7
+	}
+	return days, nil
+}
+
+const (
+	GroupByDay   = "day"
+	GroupByWeek  = "week"
+	GroupByMonth = "month"
+)
+
+/*
+GroupPeriods splits the bounds into calendar days, weeks (starting
+Monday) or months. The first and last periods are trimmed to the bounds.
+*/
+func GroupPeriods(groupBy string, bounds Interval) ([]UptimeWindow, error) {
+	loc := bounds.Start.Location()
+	start := time.Date(bounds.Start.Year(), bounds.Start.Month(), bounds.Start.Day(), 0, 0, 0, 0, loc)
+
+	var next func(time.Time) time.Time
+	var label func(time.Time) string
+
+	switch groupBy {
+	case GroupByDay:
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+		label = func(t time.Time) string { return t.Format("2006-01-02") }
+	case GroupByWeek:
+		offset := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -offset)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+		label = func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+	case GroupByMonth:
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, loc)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+		label = func(t time.Time) string { return t.Format("2006-01") }
+	default:
+		return nil, errors.New(fmt.Sprintf("'%s' is an invalid group by value.", groupBy))
+	}
+
+	periods := make([]UptimeWindow, 0)
+	for start.Before(bounds.End) {
+		end := next(start)
+		period, ok := Interval{Start: start, End: end}.Clip(bounds)
+		if ok {
+			periods = append(periods, UptimeWindow{Label: label(start), Interval: period})
+		}
+		start = end
+	}
+
+	return periods, nil
+}
+
+/*
+ParseReportTime accepts a date, a date and time, an RFC3339 timestamp
+or a duration (see ParseDays) before the given time.
+
+Examples:
+
+	2022-09-01
+	2022-09-01 13:30
+	2022-09-01T13:30:00-04:00
+	7d
+*/
+func ParseReportTime(text string, now time.Time) (time.Time, error) {
+	text = strings.TrimSpace(text)
+
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		t, err := time.ParseInLocation(layout, text, now.Location())
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	t, err := time.Parse(time.RFC3339, text)
+	if err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDays(text)
+	if err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("'%s' is an invalid date or time.", text))
+}
+
+// UptimeStats contains the availability figures of a monitor over a window.
+// All durations are in seconds. Maintenance is the time recorded as MAINT,
+// which is not counted as monitored.
+type UptimeStats struct {
+	Window          string    `json:"window"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Monitored       float64   `json:"monitored"`
+	Downtime        float64   `json:"downtime"`
+	Maintenance     float64   `json:"maintenance"`
+	Uptime          float64   `json:"uptime"`
+	Incidents       int       `json:"incidents"`
+	Mttr            float64   `json:"mttr"`
+	Mtbf            float64   `json:"mtbf"`
+	SlaTarget       float64   `json:"sla-target,omitempty"`
+	ErrorBudget     float64   `json:"error-budget,omitempty"`
+	BudgetRemaining float64   `json:"budget-remaining,omitempty"`
+}
+
+func secondsText(seconds float64) string {
+	text := DurationString(time.Duration(seconds * float64(time.Second)))
+	if text == "" {
+		return "0 minutes"
+	}
+	return text
+}
+
+func (u UptimeStats) UptimeText() string {
+	return fmt.Sprintf("%.3f%%", u.Uptime)
+}
+
+func (u UptimeStats) SlaText() string {
+	return strconv.FormatFloat(u.SlaTarget, 'f', -1, 64) + "%"
+}
+
+func (u UptimeStats) DowntimeText() string {
+	return secondsText(u.Downtime)
+}
+
+func (u UptimeStats) MaintenanceText() string {
+	return secondsText(u.Maintenance)
+}
+
+func (u UptimeStats) MttrText() string {
+	return secondsText(u.Mttr)
+}
+
+func (u UptimeStats) MtbfText() string {
+	return secondsText(u.Mtbf)
+}
+
+func (u UptimeStats) BudgetRemainingText() string {
+	if u.BudgetRemaining < 0 {
+		return fmt.Sprintf("exceeded by %s", secondsText(-u.BudgetRemaining))
+	}
+	return fmt.Sprintf("%s remaining", secondsText(u.BudgetRemaining))
+}
+
+/*
+statusSpans converts the chronological records into contiguous spans.
+A status is considered to hold until the next record starts, while the
+final record ends at its last check.
+*/
+func statusSpans(records []ReportRecord) []ReportRecord {
+	spans := make([]ReportRecord, len(records))
+	copy(spans, records)
+	for i := 0; i < len(spans)-1; i++ {
+		spans[i].End = spans[i+1].Start
+	}
+	return spans
+}
+
+/*
+CalculateUptime computes the uptime statistics of the records over the
+window. Time covered by the excluded intervals (i.e. ignore periods),
+time recorded as MAINT and time before the first check are not counted as
+monitored.
+*/
+func CalculateUptime(records []ReportRecord, window UptimeWindow, excluded []Interval, slaTarget float64) UptimeStats {
+	stats := UptimeStats{
+		Window: window.Label,
+		Start:  window.Start,
+		End:    window.End,
+		Uptime: 100.0,
+	}
+
+	excluded = MergeIntervals(excluded)
+
+	var monitored, downtime, maintenance time.Duration
+	for _, span := range statusSpans(records) {
+		clipped, ok := Interval{Start: span.Start, End: span.End}.Clip(window.Interval)
+		if !ok {
+			continue
+		}
+
+		d := clipped.Duration()
+		for _, ex := range excluded {
+			d -= clipped.Overlap(ex)
+		}
+		if d <= 0 {
+			continue
+		}
+
+		if span.Status == MAINT {
+			maintenance += d
+			continue
+		}
+
+		monitored += d
+		if span.Status == FAIL {
+			downtime += d
+			stats.Incidents += 1
+		}
+	}
+
+	stats.Monitored = monitored.Seconds()
+	stats.Downtime = downtime.Seconds()
+	stats.Maintenance = maintenance.Seconds()
+
+	if monitored > 0 {
+		stats.Uptime = (stats.Monitored - stats.Downtime) / stats.Monitored * 100.0
+	}
+
+	if stats.Incidents > 0 {
+		stats.Mttr = stats.Downtime / float64(stats.Incidents)
+		stats.Mtbf = (stats.Monitored - stats.Downtime) / float64(stats.Incidents)
+	}
+
+	if slaTarget > 0 {
+		stats.SlaTarget = slaTarget
+		stats.ErrorBudget = stats.Monitored * (100.0 - slaTarget) / 100.0
+		stats.BudgetRemaining = stats.ErrorBudget - stats.Downtime
+	}
+
+	return stats
+}
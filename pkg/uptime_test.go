@@ -0,0 +1,218 @@
+package pkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseDays(t *testing.T) {
+	d, _ := ParseDays("24h")
+	assert.Equal(t, 24*time.Hour, d)
+
+	d, _ = ParseDays("7d")
+	assert.Equal(t, 7*24*time.Hour, d)
+
+	d, _ = ParseDays("2w")
+	assert.Equal(t, 14*24*time.Hour, d)
+
+	_, err := ParseDays("week")
+	assert.NotNil(t, err)
+}
+
+func TestParseUptimeWindow(t *testing.T) {
+	now := time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC)
+
+	window, _ := ParseUptimeWindow("24h", now)
+	assert.Equal(t, time.Date(2022, 9, 2, 12, 0, 0, 0, time.UTC), window.Start)
+	assert.Equal(t, now, window.End)
+
+	window, _ = ParseUptimeWindow("month", now)
+	assert.Equal(t, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), window.Start)
+
+	_, err := ParseUptimeWindow("fortnight", now)
+	assert.NotNil(t, err)
+}
+
+func testUptimeRecords() []ReportRecord {
+	return []ReportRecord{
+		{
+			Start:  time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2022, 9, 1, 9, 55, 0, 0, time.UTC),
+			Status: PASS,
+		},
+		{
+			Start:  time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC),
+			End:    time.Date(2022, 9, 1, 10, 55, 0, 0, time.UTC),
+			Status: FAIL,
+		},
+		{
+			Start:  time.Date(2022, 9, 1, 11, 0, 0, 0, time.UTC),
+			End:    time.Date(2022, 9, 1, 21, 55, 0, 0, time.UTC),
+			Status: PASS,
+		},
+		{
+			Start:  time.Date(2022, 9, 1, 22, 0, 0, 0, time.UTC),
+			End:    time.Date(2022, 9, 1, 22, 55, 0, 0, time.UTC),
+			Status: FAIL,
+		},
+		{
+			Start:  time.Date(2022, 9, 1, 23, 0, 0, 0, time.UTC),
+			End:    time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC),
+			Status: PASS,
+		},
+	}
+}
+
+func TestCalculateUptime(t *testing.T) {
+	window := UptimeWindow{
+		Label: "24h",
+		Interval: Interval{
+			Start: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	stats := CalculateUptime(testUptimeRecords(), window, nil, 99.0)
+
+	assert.Equal(t, 86400.0, stats.Monitored)
+	assert.Equal(t, 7200.0, stats.Downtime)
+	assert.Equal(t, 2, stats.Incidents)
+	assert.InDelta(t, 91.667, stats.Uptime, 0.001)
+	assert.Equal(t, 3600.0, stats.Mttr)
+	assert.Equal(t, 39600.0, stats.Mtbf)
+	assert.Equal(t, 864.0, stats.ErrorBudget)
+	assert.Equal(t, -6336.0, stats.BudgetRemaining)
+	assert.Equal(t, "exceeded by 1 hour and 45 minutes", stats.BudgetRemainingText())
+}
+
+func TestCalculateUptimeExcludesIgnorePeriods(t *testing.T) {
+	window := UptimeWindow{
+		Label: "24h",
+		Interval: Interval{
+			Start: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	excluded, err := IgnorePeriodIntervals([]string{"22:00 - 23:00"}, window.Start, window.End)
+	assert.Nil(t, err)
+
+	stats := CalculateUptime(testUptimeRecords(), window, excluded, 0)
+
+	assert.Equal(t, 82800.0, stats.Monitored)
+	assert.Equal(t, 3600.0, stats.Downtime)
+	assert.Equal(t, 1, stats.Incidents)
+	assert.Equal(t, 0.0, stats.ErrorBudget)
+}
+
+func TestCalculateUptimeMaintenance(t *testing.T) {
+	window := UptimeWindow{
+		Label: "24h",
+		Interval: Interval{
+			Start: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	records := testUptimeRecords()
+	records[3].Status = MAINT
+
+	stats := CalculateUptime(records, window, nil, 0)
+
+	assert.Equal(t, 82800.0, stats.Monitored)
+	assert.Equal(t, 3600.0, stats.Downtime)
+	assert.Equal(t, 3600.0, stats.Maintenance)
+	assert.Equal(t, "1 hour", stats.MaintenanceText())
+	assert.Equal(t, 1, stats.Incidents)
+}
+
+func TestCalculateUptimeNoData(t *testing.T) {
+	window := UptimeWindow{
+		Label: "24h",
+		Interval: Interval{
+			Start: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	stats := CalculateUptime(testUptimeRecords(), window, nil, 0)
+
+	assert.Equal(t, 0.0, stats.Monitored)
+	assert.Equal(t, 100.0, stats.Uptime)
+	assert.Equal(t, 0, stats.Incidents)
+}
+
+func TestTimePeriodOccurrences(t *testing.T) {
+	b, e, _ := ParseTimePeriod("SAT 10:00PM - SUN 1:00AM")
+	to := NewTimeout(b, e)
+
+	from := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2022, 10, 16, 0, 0, 0, 0, time.UTC)
+	occurrences := to.Occurrences(from, until)
+
+	assert.Equal(t, []Interval{
+		{Start: time.Date(2022, 10, 1, 22, 0, 0, 0, time.UTC), End: time.Date(2022, 10, 2, 1, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2022, 10, 8, 22, 0, 0, 0, time.UTC), End: time.Date(2022, 10, 9, 1, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2022, 10, 15, 22, 0, 0, 0, time.UTC), End: time.Date(2022, 10, 16, 1, 0, 0, 0, time.UTC)},
+	}, occurrences)
+}
+
+func TestMergeIntervals(t *testing.T) {
+	base := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	merged := MergeIntervals([]Interval{
+		{Start: base.Add(2 * time.Hour), End: base.Add(3 * time.Hour)},
+		{Start: base, End: base.Add(time.Hour)},
+		{Start: base.Add(30 * time.Minute), End: base.Add(90 * time.Minute)},
+	})
+
+	assert.Equal(t, []Interval{
+		{Start: base, End: base.Add(90 * time.Minute)},
+		{Start: base.Add(2 * time.Hour), End: base.Add(3 * time.Hour)},
+	}, merged)
+}
+
+func TestGroupPeriods(t *testing.T) {
+	bounds := Interval{
+		Start: time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC),
+		End:   time.Date(2022, 9, 3, 6, 0, 0, 0, time.UTC),
+	}
+
+	periods, _ := GroupPeriods(GroupByDay, bounds)
+	assert.Equal(t, 3, len(periods))
+	assert.Equal(t, "2022-09-01", periods[0].Label)
+	assert.Equal(t, bounds.Start, periods[0].Start)
+	assert.Equal(t, time.Date(2022, 9, 2, 0, 0, 0, 0, time.UTC), periods[0].End)
+	assert.Equal(t, "2022-09-03", periods[2].Label)
+	assert.Equal(t, bounds.End, periods[2].End)
+
+	// 2022-09-01 is a Thursday, so the first week starts Monday 2022-08-29.
+	bounds.End = time.Date(2022, 9, 20, 0, 0, 0, 0, time.UTC)
+	periods, _ = GroupPeriods(GroupByWeek, bounds)
+	assert.Equal(t, 4, len(periods))
+	assert.Equal(t, "2022-W35", periods[0].Label)
+	assert.Equal(t, time.Date(2022, 9, 5, 0, 0, 0, 0, time.UTC), periods[1].Start)
+
+	bounds.End = time.Date(2022, 11, 15, 0, 0, 0, 0, time.UTC)
+	periods, _ = GroupPeriods(GroupByMonth, bounds)
+	assert.Equal(t, []string{"2022-09", "2022-10", "2022-11"}, []string{periods[0].Label, periods[1].Label, periods[2].Label})
+
+	_, err := GroupPeriods("year", bounds)
+	assert.NotNil(t, err)
+}
+
+func TestParseReportTime(t *testing.T) {
+	now := time.Date(2022, 9, 3, 12, 0, 0, 0, time.UTC)
+
+	tm, _ := ParseReportTime("2022-09-01", now)
+	assert.Equal(t, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), tm)
+
+	tm, _ = ParseReportTime("2022-09-01 13:30", now)
+	assert.Equal(t, time.Date(2022, 9, 1, 13, 30, 0, 0, time.UTC), tm)
+
+	tm, _ = ParseReportTime("2d", now)
+	assert.Equal(t, time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC), tm)
+
+	_, err := ParseReportTime("yesterday", now)
+	assert.NotNil(t, err)
+}
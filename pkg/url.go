@@ -1,36 +1,196 @@
 package pkg
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// UrlTiming records how long each phase of the request took.
+type UrlTiming struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+// DefaultMaxRedirects is the number of redirects followed unless set otherwise.
+const DefaultMaxRedirects = 10
+
+// Redirect is a single hop of a redirect chain.
+type Redirect struct {
+	Url        string `json:"url"`
+	StatusCode int    `json:"status"`
+	Location   string `json:"location"`
+}
+
+/*
+RedirectLimit is the number of redirects to follow, or "none" to return
+the first redirect response. It is read from json as either a number or
+a string.
+*/
+type RedirectLimit string
+
+func (l *RedirectLimit) UnmarshalJSON(data []byte) error {
+	text, err := unmarshalNumberOrString(data)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid redirect limit %s", string(data)))
+	}
+	*l = RedirectLimit(text)
+	return nil
+}
+
+// Max returns the number of redirects to follow, DefaultMaxRedirects if
+// the limit is empty.
+func (l RedirectLimit) Max() (int, error) {
+	if l == "" {
+		return DefaultMaxRedirects, nil
+	}
+	if l == "none" {
+		return 0, nil
+	}
+	max, err := strconv.Atoi(string(l))
+	if err != nil || max < 0 {
+		return 0, errors.New(fmt.Sprintf("invalid redirect limit '%s', expecting a number or 'none'", l))
+	}
+	return max, nil
+}
+
+func (r Redirect) String() string {
+	return fmt.Sprintf("%d %s -> %s", r.StatusCode, r.Url, r.Location)
+}
+
 type UrlResult struct {
 	StatusCode int
 	Content    string
 	Fail       bool
+	Error      string
+	Timing     UrlTiming
+	CertExpiry time.Time
+	FinalUrl   string
+	Redirects  []Redirect
+	Header     http.Header
+}
+
+// UrlRequest is a request other than a plain GET of the url.
+type UrlRequest struct {
+	Method string
+	Url    string
+	Header http.Header
+	Body   string
+}
+
+// traceTiming returns a ClientTrace that fills in the timing phases.
+func traceTiming(start time.Time, timing *UrlTiming) *httptrace.ClientTrace {
+	var dnsStart, connectStart, tlsStart time.Time
+	return &httptrace.ClientTrace{
+		DNSStart: func(_ httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(_ httptrace.DNSDoneInfo) {
+			timing.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(_, _ string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, _ error) {
+			timing.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			timing.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			timing.FirstByte = time.Since(start)
+		},
+	}
 }
 
 func UrlFetch(url string) UrlResult {
-	result := UrlResult{Fail: false}
+	return UrlFetchContext(context.Background(), http.DefaultClient, url, DefaultMaxRedirects)
+}
+
+/*
+UrlFetchContext fetches the url with the client, cancelling the request
+when the context is done. Up to maxRedirects redirects are followed, and
+a further redirect is returned as the response. Every redirect response
+is recorded in the redirect chain of the result.
+*/
+func UrlFetchContext(ctx context.Context, client *http.Client, url string, maxRedirects int) UrlResult {
+	return UrlRequestContext(ctx, client, UrlRequest{Method: http.MethodGet, Url: url}, maxRedirects)
+}
+
+// UrlRequestContext sends the request as UrlFetchContext fetches a url.
+func UrlRequestContext(ctx context.Context, client *http.Client, request UrlRequest, maxRedirects int) UrlResult {
+	result := UrlResult{Fail: false, Redirects: make([]Redirect, 0)}
 
-	resp, err := http.Get(url)
+	redirecting := *client
+	redirecting.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		result.Redirects = append(result.Redirects, Redirect{
+			Url:        via[len(via)-1].URL.String(),
+			StatusCode: req.Response.StatusCode,
+			Location:   req.URL.String(),
+		})
+		return nil
+	}
+
+	start := time.Now()
+	var requestBody io.Reader
+	if request.Body != "" {
+		requestBody = strings.NewReader(request.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, request.Method, request.Url, requestBody)
 	if err != nil {
 		result.Fail = true
+		result.Error = err.Error()
 		return result
 	}
+	for name, values := range request.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), traceTiming(start, &result.Timing)))
+
+	resp, err := redirecting.Do(req)
+	if err != nil {
+		result.Fail = true
+		result.Error = err.Error()
+		result.Timing.Total = time.Since(start)
+		return result
+	}
+	defer resp.Body.Close()
 
 	result.StatusCode = resp.StatusCode
+	result.Header = resp.Header
+	result.FinalUrl = resp.Request.URL.String()
+	if location, err := resp.Location(); err == nil && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		result.Redirects = append(result.Redirects, Redirect{
+			Url:        result.FinalUrl,
+			StatusCode: resp.StatusCode,
+			Location:   location.String(),
+		})
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.CertExpiry = resp.TLS.PeerCertificates[0].NotAfter
+	}
 
 	body, err := io.ReadAll(resp.Body)
-	PanicOnError(err)
-
+	result.Timing.Total = time.Since(start)
+	if err != nil {
+		result.Fail = true
+		result.Error = err.Error()
+		return result
+	}
 	result.Content = string(body)
 
-	err = resp.Body.Close()
-	IgnoreOnError(err)
-
 	return result
 }
 
@@ -39,6 +199,7 @@ type UrlCheck struct {
 	Assertions []*Assertion
 	Pass       bool
 	Errors     []string
+	Result     UrlResult
 }
 
 func NewUrlCheck(url string, assertions []*Assertion) *UrlCheck {
@@ -63,31 +224,13 @@ func ErrMsg(errMsg string) string {
 	return ""
 }
 
-func (u *UrlCheck) Test() {
+func (u *UrlCheck) Test(console Logger) {
 	console.Trace("Fetching url: %s\n", u.Url)
-	result := UrlFetch(u.Url)
+	result, _ := runCheck(context.Background(), http.DefaultClient, u.Url, DefaultMaxRedirects, u.Assertions)
 
-	console.Indent()
-
-	if result.Fail {
-		console.Trace("Failed to fetch url!")
-		u.Pass = false
-		u.Errors = append(u.Errors, "Could not fetch url.")
-		return
-	}
-
-	for _, assertion := range u.Assertions {
-		assert := *assertion
-		passed, errMsg := assert.Assert(&result)
-		console.Trace("%s %s%s\n", assert.Name(), PassFail(passed), ErrMsg(errMsg))
-
-		u.Pass = passed
-		if passed == false {
-			console.Print("GET %s %s.\n", u.Url, errMsg)
-			u.Errors = append(u.Errors, errMsg)
-			break
-		}
-	}
+	u.Result = result.Response
+	u.Pass = result.Pass
+	u.Errors = result.Errors
 
-	console.Dedent()
+	LogResult(result, console)
 }
@@ -1,22 +1,45 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+/*
+Assertion tests a single property of a fetched url. The failure of a
+critical assertion fails the check, while the failure of a warning is
+only reported.
+*/
 type Assertion interface {
 	Assert(test *UrlResult) (bool, string)
 	Name() string
+	Severity() string
+	Expected() string
+	Actual(test *UrlResult) string
 }
 
 type StatusCodeAssertion struct {
-	Expected int
+	Status *StatusSet
 }
 
 func NewStatusCodeAssertion(expected int) *StatusCodeAssertion {
 	return &StatusCodeAssertion{
-		Expected: expected,
+		Status: NewStatusSet(expected),
+	}
+}
+
+// NewStatusSetAssertion creates an assertion that the status is in the set.
+func NewStatusSetAssertion(status *StatusSet) *StatusCodeAssertion {
+	return &StatusCodeAssertion{
+		Status: status,
 	}
 }
 
@@ -24,37 +47,344 @@ func (a *StatusCodeAssertion) Name() string {
 	return "Status Code Assertion"
 }
 
+func (a *StatusCodeAssertion) Severity() string {
+	return SeverityCritical
+}
+
+func (a *StatusCodeAssertion) Expected() string {
+	return string(a.Status.Expression)
+}
+
+func (a *StatusCodeAssertion) Actual(test *UrlResult) string {
+	return strconv.Itoa(test.StatusCode)
+}
+
 func (a *StatusCodeAssertion) Assert(test *UrlResult) (bool, string) {
-	pass := a.Expected == test.StatusCode
+	pass := a.Status.Match(test.StatusCode)
 	if !pass {
-		return pass, fmt.Sprintf("expecting status of %d, but received %d", a.Expected, test.StatusCode)
+		return pass, fmt.Sprintf("expecting status %s, but received %d", a.Status.Describe(), test.StatusCode)
 	}
 	return pass, ""
 }
 
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+/*
+ContentRule is a pattern the response content must contain, or must not
+contain when negated. The pattern is a regex unless it is literal. A rule
+with a warning severity is only reported as a warning.
+*/
+type ContentRule struct {
+	Pattern    string `json:"pattern"`
+	Negate     bool   `json:"negate"`
+	IgnoreCase bool   `json:"ignore-case"`
+	Literal    bool   `json:"literal"`
+	Severity   string `json:"severity"`
+}
+
+// Compile returns the regex that matches the rule's pattern.
+func (r *ContentRule) Compile() (*regexp.Regexp, error) {
+	pattern := r.Pattern
+	if r.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if r.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid content pattern '%s': %s", r.Pattern, err))
+	}
+	return re, nil
+}
+
+func (r *ContentRule) String() string {
+	text := r.Pattern
+	if r.Negate {
+		text = "not " + text
+	}
+	if r.IgnoreCase {
+		text += " (ignore case)"
+	}
+	return text
+}
+
+/*
+ContentAssertion checks the content against one or more rules. With the
+MatchAll mode every rule must hold, with MatchAny at least one of them.
+The rule patterns are compiled when the assertion is created.
+*/
 type ContentAssertion struct {
-	Regex string
+	Rules   []ContentRule
+	Match   string
+	regexes []*regexp.Regexp
+	err     error
 }
 
+// NewContentAssertion creates an assertion that the content matches the regex.
+// An invalid regex fails the assertion, use NewContentRulesAssertion to
+// validate it first.
 func NewContentAssertion(regex string) *ContentAssertion {
-	return &ContentAssertion{
-		Regex: regex,
+	a, err := NewContentRulesAssertion([]ContentRule{{Pattern: regex}}, MatchAll)
+	if err != nil {
+		return &ContentAssertion{Rules: a.Rules, Match: MatchAll, err: err}
+	}
+	return a
+}
+
+// NewContentRulesAssertion creates an assertion of the rules, returning an
+// error if any of the rule patterns is invalid.
+func NewContentRulesAssertion(rules []ContentRule, match string) (*ContentAssertion, error) {
+	if match == "" {
+		match = MatchAll
 	}
+	a := &ContentAssertion{Rules: rules, Match: match}
+	if match != MatchAll && match != MatchAny {
+		return a, errors.New(fmt.Sprintf("invalid content match '%s', expecting '%s' or '%s'", match, MatchAll, MatchAny))
+	}
+	for i := range rules {
+		re, err := rules[i].Compile()
+		if err != nil {
+			return a, err
+		}
+		a.regexes = append(a.regexes, re)
+	}
+	return a, nil
 }
 
 func (a *ContentAssertion) Name() string {
-	return "Content Assertion"
+	for _, rule := range a.Rules {
+		if !rule.Negate {
+			return "Content Assertion"
+		}
+	}
+	return "Rejected Content Assertion"
+}
+
+func (a *ContentAssertion) Severity() string {
+	return SeverityCritical
+}
+
+func (a *ContentAssertion) Expected() string {
+	rules := make([]string, 0, len(a.Rules))
+	for i := range a.Rules {
+		rules = append(rules, a.Rules[i].String())
+	}
+	if a.Match == MatchAny {
+		return strings.Join(rules, " or ")
+	}
+	return strings.Join(rules, " and ")
+}
+
+// Actual returns the text matched by each rule.
+func (a *ContentAssertion) Actual(test *UrlResult) string {
+	if a.err != nil {
+		return ""
+	}
+	matches := make([]string, 0, len(a.regexes))
+	for _, re := range a.regexes {
+		loc := re.FindStringIndex(test.Content)
+		if loc == nil {
+			matches = append(matches, "no match")
+		} else {
+			matches = append(matches, test.Content[loc[0]:loc[1]])
+		}
+	}
+	return strings.Join(matches, ", ")
+}
+
+// failure returns why the rule does not hold for the content, or "" if it does.
+func (a *ContentAssertion) failure(i int, content string) string {
+	rule := a.Rules[i]
+	found := a.regexes[i].MatchString(content)
+	if rule.Negate && found {
+		return fmt.Sprintf("contains the rejected text '%s'", rule.Pattern)
+	}
+	if !rule.Negate && !found {
+		if len(a.Rules) == 1 {
+			return "does not contain the expected text"
+		}
+		return fmt.Sprintf("does not contain the expected text '%s'", rule.Pattern)
+	}
+	return ""
 }
 
 func (a *ContentAssertion) Assert(test *UrlResult) (bool, string) {
-	re := regexp.MustCompile(a.Regex)
-	result := re.FindStringIndex(test.Content)
-	if result == nil {
-		return false, "does not contain the expected text"
+	if a.err != nil {
+		return false, a.err.Error()
+	}
+
+	failures := make([]string, 0)
+	for i := range a.Rules {
+		failure := a.failure(i, test.Content)
+		if failure == "" && a.Match == MatchAny {
+			return true, ""
+		}
+		if failure != "" {
+			failures = append(failures, failure)
+		}
+	}
+
+	if len(failures) == 0 {
+		return true, ""
+	}
+	if a.Match == MatchAny && len(failures) > 1 {
+		return false, "matches none of the content rules: " + strings.Join(failures, ", ")
+	}
+	return false, strings.Join(failures, ", ")
+}
+
+// FinalUrlAssertion checks the url of the last response, after any redirects.
+type FinalUrlAssertion struct {
+	Pattern string
+	regex   *regexp.Regexp
+}
+
+func NewFinalUrlAssertion(pattern string) (*FinalUrlAssertion, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid final url pattern '%s': %s", pattern, err))
+	}
+	return &FinalUrlAssertion{Pattern: pattern, regex: re}, nil
+}
+
+func (a *FinalUrlAssertion) Name() string {
+	return "Final Url Assertion"
+}
+
+func (a *FinalUrlAssertion) Severity() string {
+	return SeverityCritical
+}
+
+func (a *FinalUrlAssertion) Expected() string {
+	return a.Pattern
+}
+
+func (a *FinalUrlAssertion) Actual(test *UrlResult) string {
+	return test.FinalUrl
+}
+
+func (a *FinalUrlAssertion) Assert(test *UrlResult) (bool, string) {
+	if !a.regex.MatchString(test.FinalUrl) {
+		return false, fmt.Sprintf("final url %s does not match '%s'", test.FinalUrl, a.Pattern)
+	}
+	return true, ""
+}
+
+// RedirectCountAssertion checks the number of redirects in the chain.
+type RedirectCountAssertion struct {
+	Count int
+}
+
+func NewRedirectCountAssertion(count int) *RedirectCountAssertion {
+	return &RedirectCountAssertion{Count: count}
+}
+
+func (a *RedirectCountAssertion) Name() string {
+	return "Redirect Count Assertion"
+}
+
+func (a *RedirectCountAssertion) Severity() string {
+	return SeverityCritical
+}
+
+func (a *RedirectCountAssertion) Expected() string {
+	return strconv.Itoa(a.Count)
+}
+
+func (a *RedirectCountAssertion) Actual(test *UrlResult) string {
+	return strconv.Itoa(len(test.Redirects))
+}
+
+func (a *RedirectCountAssertion) Assert(test *UrlResult) (bool, string) {
+	if len(test.Redirects) != a.Count {
+		return false, fmt.Sprintf("expecting %d redirects, but received %d", a.Count, len(test.Redirects))
 	}
 	return true, ""
 }
 
+// RedirectTargetAssertion checks that a redirect of the chain has a location
+// matching the pattern.
+type RedirectTargetAssertion struct {
+	Pattern string
+	regex   *regexp.Regexp
+}
+
+func NewRedirectTargetAssertion(pattern string) (*RedirectTargetAssertion, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid redirect target pattern '%s': %s", pattern, err))
+	}
+	return &RedirectTargetAssertion{Pattern: pattern, regex: re}, nil
+}
+
+func (a *RedirectTargetAssertion) Name() string {
+	return "Redirect Target Assertion"
+}
+
+func (a *RedirectTargetAssertion) Severity() string {
+	return SeverityCritical
+}
+
+func (a *RedirectTargetAssertion) Expected() string {
+	return a.Pattern
+}
+
+// Actual returns the locations of the redirect chain.
+func (a *RedirectTargetAssertion) Actual(test *UrlResult) string {
+	locations := make([]string, 0, len(test.Redirects))
+	for _, redirect := range test.Redirects {
+		locations = append(locations, redirect.Location)
+	}
+	return strings.Join(locations, " -> ")
+}
+
+func (a *RedirectTargetAssertion) Assert(test *UrlResult) (bool, string) {
+	for _, redirect := range test.Redirects {
+		if a.regex.MatchString(redirect.Location) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("no redirect to '%s'", a.Pattern)
+}
+
+// WarningAssertion reports the failure of the assertion it wraps as a warning.
+type WarningAssertion struct {
+	Assertion
+}
+
+func NewWarningAssertion(assertion Assertion) *WarningAssertion {
+	return &WarningAssertion{
+		Assertion: assertion,
+	}
+}
+
+func (a *WarningAssertion) Severity() string {
+	return SeverityWarning
+}
+
+// ValidateSeverity returns an error if the severity is not critical or
+// warning. An empty severity is critical.
+func ValidateSeverity(severity string) error {
+	switch severity {
+	case "", SeverityCritical, SeverityWarning:
+		return nil
+	}
+	return errors.New(fmt.Sprintf("'%s' is an unknown severity, expecting critical or warning.", severity))
+}
+
+// withSeverity wraps the assertion in a WarningAssertion if the severity
+// is a warning.
+func withSeverity(assertion Assertion, severity string) Assertion {
+	if severity == SeverityWarning {
+		return NewWarningAssertion(assertion)
+	}
+	return assertion
+}
+
 func BuildAssertions(expectedStatus int, expectedContent string) *[]*Assertion {
 	assertions := make([]*Assertion, 0)
 
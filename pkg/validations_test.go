@@ -64,3 +64,69 @@ func TestContentAssertionFail(t *testing.T) {
 	assert.False(t, pass)
 	assert.Equal(t, errMsg, "does not contain the expected text")
 }
+
+func TestContentAssertionInvalidRegex(t *testing.T) {
+
+	urlResult := UrlResult{
+		StatusCode: 200,
+		Content:    "active",
+		Fail:       false,
+	}
+
+	_, err := NewContentRulesAssertion([]ContentRule{{Pattern: "(active"}}, MatchAll)
+	assert.NotNil(t, err)
+
+	test := NewContentAssertion("(active")
+	pass, errMsg := test.Assert(&urlResult)
+
+	assert.False(t, pass)
+	assert.Contains(t, errMsg, "invalid content pattern '(active'")
+}
+
+func TestContentRulesAssertion(t *testing.T) {
+
+	urlResult := UrlResult{
+		StatusCode: 200,
+		Content:    "<h1>502 Bad Gateway</h1> Status: Active (v1.2)",
+		Fail:       false,
+	}
+
+	var tests = []struct {
+		rules  []ContentRule
+		match  string
+		pass   bool
+		errMsg string
+	}{
+		{[]ContentRule{{Pattern: "Active"}, {Pattern: "502 Bad Gateway", Negate: true}}, MatchAll, false, "contains the rejected text '502 Bad Gateway'"},
+		{[]ContentRule{{Pattern: "Exception", Negate: true}, {Pattern: "Maintenance mode", Negate: true}}, MatchAll, true, ""},
+		{[]ContentRule{{Pattern: "active"}}, MatchAll, false, "does not contain the expected text"},
+		{[]ContentRule{{Pattern: "active", IgnoreCase: true}}, MatchAll, true, ""},
+		{[]ContentRule{{Pattern: "(v1.2)", Literal: true}}, MatchAll, true, ""},
+		{[]ContentRule{{Pattern: "v1.3", Literal: true}, {Pattern: "v1.2"}}, MatchAll, false, "does not contain the expected text 'v1.3'"},
+		{[]ContentRule{{Pattern: "v1.3", Literal: true}, {Pattern: "v1.2"}}, MatchAny, true, ""},
+		{[]ContentRule{{Pattern: "ok"}, {Pattern: "healthy"}}, MatchAny, false, "matches none of the content rules: does not contain the expected text 'ok', does not contain the expected text 'healthy'"},
+	}
+
+	for _, tt := range tests {
+		test, err := NewContentRulesAssertion(tt.rules, tt.match)
+		assert.Nil(t, err)
+
+		pass, errMsg := test.Assert(&urlResult)
+		assert.Equal(t, tt.pass, pass, tt.rules)
+		assert.Equal(t, tt.errMsg, errMsg)
+	}
+}
+
+func TestContentRulesAssertionExpected(t *testing.T) {
+
+	urlResult := UrlResult{
+		StatusCode: 200,
+		Content:    "Status: Active",
+		Fail:       false,
+	}
+
+	test, err := NewContentRulesAssertion([]ContentRule{{Pattern: "active", IgnoreCase: true}, {Pattern: "Exception", Negate: true}}, MatchAll)
+	assert.Nil(t, err)
+	assert.Equal(t, "active (ignore case) and not Exception", test.Expected())
+	assert.Equal(t, "Active, no match", test.Actual(&urlResult))
+}